@@ -1,6 +1,7 @@
 package main
 
 import (
+	"WayPointPro/internal/app/services"
 	"WayPointPro/internal/config"
 	"WayPointPro/internal/routes"
 	"WayPointPro/pkg/queue"
@@ -37,6 +38,9 @@ func main() {
 	// Start Queue Processing
 	runQueue()
 
+	// Probe the routing engines for failover
+	services.SharedEngineMonitor().Start(30 * time.Second)
+
 	// Start HTTP Server in a Goroutine
 	go func() {
 		log.Printf("🚀 Server running on http://localhost:%s", cfg.Port)
@@ -91,6 +95,24 @@ func runQueue() {
 		Execute: jobs.ResetRequestLimit,
 	})
 
+	s.AddTask(24*time.Hour, queue.Job{
+		ID:      2,
+		Name:    "Fit ETA corrections",
+		Execute: jobs.FitETACorrections,
+	})
+
+	s.AddTask(15*time.Minute, queue.Job{
+		ID:      3,
+		Name:    "Collect speed samples",
+		Execute: jobs.CollectSpeedSamples,
+	})
+
+	s.AddTask(24*time.Hour, queue.Job{
+		ID:      4,
+		Name:    "Build speed profiles",
+		Execute: jobs.BuildSpeedProfiles,
+	})
+
 	// Start the scheduler
 	s.Start(q)
 
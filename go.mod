@@ -11,6 +11,7 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/ulule/limiter/v3 v3.11.2
 	golang.org/x/net v0.34.0
+	google.golang.org/protobuf v1.36.3
 )
 
 require (
@@ -45,6 +46,5 @@ require (
 	golang.org/x/sync v0.10.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
-	google.golang.org/protobuf v1.36.3 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
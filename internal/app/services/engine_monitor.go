@@ -0,0 +1,154 @@
+package services
+
+import (
+	"WayPointPro/pkg/routing"
+	"log"
+	"sync"
+	"time"
+)
+
+// An engine is marked down after this many failed probes in a row
+const engineFailureThreshold = 2
+
+// EngineState is the last known health of a routing engine
+type EngineState struct {
+	Name        string    `json:"name"`
+	Primary     bool      `json:"primary"`
+	Healthy     bool      `json:"healthy"`
+	Failures    int       `json:"consecutive_failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+	LatencyMs   int64     `json:"latency_ms"`
+}
+
+// EngineMonitor probes the registered routing engines periodically and orders them for failover
+type EngineMonitor struct {
+	mu       sync.Mutex
+	registry *routing.Registry
+	states   map[string]*EngineState
+}
+
+var (
+	engineMonitorInstance *EngineMonitor
+	engineMonitorOnce     sync.Once
+)
+
+// SharedEngineMonitor returns the process wide monitor of the shared registry, engines are healthy until probed
+func SharedEngineMonitor() *EngineMonitor {
+	engineMonitorOnce.Do(func() {
+		engineMonitorInstance = NewEngineMonitor(routing.SharedRegistry())
+	})
+	return engineMonitorInstance
+}
+
+// NewEngineMonitor returns a monitor of the engines of a registry, engines are healthy until probed
+func NewEngineMonitor(registry *routing.Registry) *EngineMonitor {
+	return &EngineMonitor{
+		registry: registry,
+		states:   map[string]*EngineState{},
+	}
+}
+
+// Registry returns the registry of the monitored engines
+func (m *EngineMonitor) Registry() *routing.Registry {
+	return m.registry
+}
+
+// Start probes the engines now and then on every interval in the background
+func (m *EngineMonitor) Start(interval time.Duration) {
+	go func() {
+		m.Probe()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.Probe()
+		}
+	}()
+}
+
+// Probe checks every engine once
+func (m *EngineMonitor) Probe() {
+	for _, name := range m.registry.Names() {
+		engine, err := m.registry.Get(name)
+		if err != nil {
+			continue
+		}
+
+		startTime := time.Now()
+		err = engine.Health()
+		latency := time.Since(startTime)
+
+		m.mu.Lock()
+		state := m.state(name)
+		state.LastChecked = time.Now()
+		state.LatencyMs = latency.Milliseconds()
+		if err != nil {
+			state.Failures++
+			state.LastError = err.Error()
+			if state.Healthy && state.Failures >= engineFailureThreshold {
+				state.Healthy = false
+				log.Printf("Routing engine %s is down: %v", name, err)
+			}
+		} else {
+			if !state.Healthy {
+				log.Printf("Routing engine %s recovered", name)
+			}
+			state.Failures = 0
+			state.LastError = ""
+			state.Healthy = true
+		}
+		m.mu.Unlock()
+	}
+}
+
+// States returns a copy of the engine states, primary first
+func (m *EngineMonitor) States() []EngineState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var states []EngineState
+	for _, name := range m.registry.Names() {
+		states = append(states, *m.state(name))
+	}
+	return states
+}
+
+// Healthy reports whether the last probe found the engine up
+func (m *EngineMonitor) Healthy(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state(name).Healthy
+}
+
+// Candidates returns the engines to try in order, a forced engine is the only candidate.
+// Healthy engines come first, down engines stay as a last resort
+func (m *EngineMonitor) Candidates(forced string) []string {
+	if forced != "" {
+		return []string{forced}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var healthy, down []string
+	for _, name := range m.registry.Names() {
+		if m.state(name).Healthy {
+			healthy = append(healthy, name)
+		} else {
+			down = append(down, name)
+		}
+	}
+	return append(healthy, down...)
+}
+
+// state returns the state of an engine, creating it for engines registered after startup.
+// The caller must hold the lock
+func (m *EngineMonitor) state(name string) *EngineState {
+	state, ok := m.states[name]
+	if !ok {
+		names := m.registry.Names()
+		state = &EngineState{Name: name, Primary: len(names) > 0 && names[0] == name, Healthy: true}
+		m.states[name] = state
+	}
+	return state
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/queue"
+	"WayPointPro/pkg/traffic"
+	"WayPointPro/pkg/vrp"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fleet plans run on their own workers so long solves never hold up the scheduled jobs
+const (
+	plannerWorkers   = 2
+	plannerQueueSize = 20
+)
+
+// MaxFleetPlanLocations caps the distinct locations of a plan so its matrix stays within the 2500 cells of /api/matrix
+const MaxFleetPlanLocations = 50
+
+// ErrPlannerBusy is returned by SubmitPlan when every planner slot is taken
+var ErrPlannerBusy = errors.New("fleet planner queue is full")
+
+var (
+	plannerQueue     *queue.Queue
+	plannerQueueOnce sync.Once
+)
+
+// sharedPlannerQueue returns the fleet planner job queue, started on first use
+func sharedPlannerQueue() *queue.Queue {
+	plannerQueueOnce.Do(func() {
+		plannerQueue = queue.NewQueueSize(plannerWorkers, plannerQueueSize)
+		go plannerQueue.Start()
+	})
+	return plannerQueue
+}
+
+type FleetPlannerService struct {
+	Aggregator *RouteAggregatorService
+	Cache      *traffic.Cache
+}
+
+func NewFleetPlannerService(trafficService *traffic.Service) *FleetPlannerService {
+	return &FleetPlannerService{
+		Aggregator: NewRouteAggregatorService(trafficService),
+		Cache:      trafficService.Cache,
+	}
+}
+
+// SubmitPlan stores a pending plan and queues the solver, the plan is polled with GetPlan.
+// It returns ErrPlannerBusy instead of waiting when the planner queue is full
+func (s *FleetPlannerService) SubmitPlan(request models.FleetPlanRequest) (*models.FleetPlanResult, error) {
+	id, err := newPlanID()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := models.FleetPlanResult{
+		ID:        id,
+		Status:    models.FleetPlanPending,
+		Message:   "Fleet plan queued",
+		CreatedAt: time.Now(),
+	}
+	if err := s.Cache.CacheFleetPlan(plan); err != nil {
+		return nil, fmt.Errorf("failed to store fleet plan: %v", err)
+	}
+
+	queued := sharedPlannerQueue().TryAddJob(queue.Job{
+		Name: "Fleet plan " + id,
+		Execute: func() {
+			s.runPlan(plan, request)
+		},
+	})
+	if !queued {
+		// The caller never gets the id, drop the pending record
+		if err := s.Cache.DeleteFleetPlan(plan.ID); err != nil {
+			log.Printf("Failed to delete fleet plan %s: %v", plan.ID, err)
+		}
+		return nil, ErrPlannerBusy
+	}
+
+	return &plan, nil
+}
+
+// GetPlan returns the current state of a plan
+func (s *FleetPlannerService) GetPlan(id string) (*models.FleetPlanResult, error) {
+	return s.Cache.GetFleetPlan(id)
+}
+
+func (s *FleetPlannerService) runPlan(plan models.FleetPlanResult, request models.FleetPlanRequest) {
+	plan.Status = models.FleetPlanRunning
+	plan.Message = "Fleet plan is running"
+	if err := s.Cache.CacheFleetPlan(plan); err != nil {
+		log.Printf("Failed to update fleet plan %s: %v", plan.ID, err)
+	}
+
+	result, err := s.solve(request)
+	completedAt := time.Now()
+	if err != nil {
+		log.Printf("Fleet plan %s failed: %v", plan.ID, err)
+		plan.Status = models.FleetPlanFailed
+		plan.Message = err.Error()
+	} else {
+		plan.Status = models.FleetPlanCompleted
+		plan.Message = "Fleet plan completed successfully!"
+		plan.Routes = result.Routes
+		plan.Unassigned = result.Unassigned
+		plan.Distance = result.Distance
+		plan.Duration = result.Duration
+	}
+	plan.CompletedAt = &completedAt
+
+	if err := s.Cache.CacheFleetPlan(plan); err != nil {
+		log.Printf("Failed to store fleet plan %s: %v", plan.ID, err)
+	}
+}
+
+// solve builds the travel-time matrix for every distinct location and runs the VRP solver
+func (s *FleetPlannerService) solve(request models.FleetPlanRequest) (*models.FleetPlanResult, error) {
+	startTime := time.Now()
+
+	// Collect distinct locations, the matrix is indexed by their position
+	var locations []string
+	locationIndex := map[string]int{}
+	indexOf := func(location string) int {
+		location = strings.TrimSpace(location)
+		if index, ok := locationIndex[location]; ok {
+			return index
+		}
+		locationIndex[location] = len(locations)
+		locations = append(locations, location)
+		return len(locations) - 1
+	}
+
+	// Plan times are seconds from the earliest shift start
+	epoch := request.Vehicles[0].ShiftStart
+	for _, vehicle := range request.Vehicles {
+		if vehicle.ShiftStart.Before(epoch) {
+			epoch = vehicle.ShiftStart
+		}
+	}
+	offset := func(t *time.Time, fallback float64) float64 {
+		if t == nil {
+			return fallback
+		}
+		return t.Sub(epoch).Seconds()
+	}
+
+	problem := vrp.Problem{}
+	for _, vehicle := range request.Vehicles {
+		end := -1
+		if vehicle.End != "" {
+			end = indexOf(vehicle.End)
+		}
+		problem.Vehicles = append(problem.Vehicles, vrp.Vehicle{
+			ID:            vehicle.ID,
+			StartLocation: indexOf(vehicle.Start),
+			EndLocation:   end,
+			Capacity:      vehicle.Capacity,
+			ShiftStart:    offset(&vehicle.ShiftStart, 0),
+			ShiftEnd:      offset(vehicle.ShiftEnd, math.Inf(1)),
+		})
+	}
+	for _, job := range request.Jobs {
+		problem.Jobs = append(problem.Jobs, vrp.Job{
+			ID:          job.ID,
+			Location:    indexOf(job.Location),
+			Demand:      job.Demand,
+			Service:     job.ServiceTime,
+			WindowStart: offset(job.WindowStart, 0),
+			WindowEnd:   offset(job.WindowEnd, math.Inf(1)),
+		})
+	}
+
+	if len(locations) > MaxFleetPlanLocations {
+		return nil, fmt.Errorf("a fleet plan accepts at most %d distinct locations, got %d", MaxFleetPlanLocations, len(locations))
+	}
+	coordinates := strings.Join(locations, ";")
+	table, err := s.Aggregator.GetAggregatedMatrix(coordinates, coordinates, map[string]string{"costing": "auto"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch travel-time matrix: %v", err)
+	}
+	problem.Durations = matrixValues(table.Durations, len(locations))
+	problem.Distances = matrixValues(table.Distances, len(locations))
+
+	solution := vrp.Solve(problem, vrp.Options{TimeLimit: 20 * time.Second})
+	log.Printf("Fleet plan execution time: %v", time.Since(startTime))
+
+	at := func(seconds float64) time.Time {
+		return epoch.Add(time.Duration(seconds * float64(time.Second)))
+	}
+
+	result := &models.FleetPlanResult{
+		Distance: models.DirectionsValueObject{
+			Value: solution.TotalDistance,
+			Text:  fmt.Sprintf("%.1f km", math.Round(solution.TotalDistance*10)/10),
+		},
+		Duration: models.DirectionsValueObject{
+			Value: math.Round(solution.TotalDuration*10) / 10,
+			Text:  fmt.Sprintf("%.1f mins", math.Round(solution.TotalDuration/60*10)/10),
+		},
+		Routes:     []models.FleetRoute{},
+		Unassigned: []models.FleetUnassigned{},
+	}
+	for _, route := range solution.Routes {
+		fleetRoute := models.FleetRoute{
+			VehicleID: route.VehicleID,
+			Load:      route.Load,
+			Start:     at(route.Start),
+			End:       at(route.End),
+			Distance: models.DirectionsValueObject{
+				Value: route.Distance,
+				Text:  fmt.Sprintf("%.1f km", math.Round(route.Distance*10)/10),
+			},
+			Duration: models.DirectionsValueObject{
+				Value: math.Round(route.Duration*10) / 10,
+				Text:  fmt.Sprintf("%.1f mins", math.Round(route.Duration/60*10)/10),
+			},
+		}
+		for _, stop := range route.Stops {
+			fleetRoute.Stops = append(fleetRoute.Stops, models.FleetStop{
+				JobID:     stop.JobID,
+				Location:  locations[stop.Location],
+				Arrival:   at(stop.Arrival),
+				Departure: at(stop.Departure),
+				Wait:      math.Round(stop.Wait*10) / 10,
+				Load:      stop.Load,
+			})
+		}
+		result.Routes = append(result.Routes, fleetRoute)
+	}
+	for _, unassigned := range solution.Unassigned {
+		result.Unassigned = append(result.Unassigned, models.FleetUnassigned{
+			JobID:  unassigned.JobID,
+			Reason: unassigned.Reason,
+		})
+	}
+
+	return result, nil
+}
+
+// matrixValues converts engine cells to a dense matrix, missing cells become +Inf
+func matrixValues(cells [][]*float64, size int) [][]float64 {
+	values := make([][]float64, size)
+	for i := range values {
+		values[i] = make([]float64, size)
+		for j := range values[i] {
+			values[i][j] = math.Inf(1)
+			if i == j {
+				values[i][j] = 0
+			}
+			if i < len(cells) && j < len(cells[i]) && cells[i][j] != nil {
+				values[i][j] = *cells[i][j]
+			}
+		}
+	}
+	return values
+}
+
+// newPlanID generates a random identifier for a fleet plan
+func newPlanID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/routing"
+	"log"
+	"time"
+)
+
+// Maximum number of points sent to the engine in one match request, OSRM defaults to 100
+const maxMatchChunk = 100
+
+// MatchTrace splits the trace on large time or distance gaps and matches every piece on its own,
+// so a gap such as a tunnel only drops its own points instead of failing the whole trip
+func (s *RouteAggregatorService) MatchTrace(points []models.TracePoint, maxGapSeconds, maxGapMeters float64, options map[string]string) []models.MatchSegment {
+	startTime := time.Now()
+
+	var segments []models.MatchSegment
+	for _, indexes := range s.splitTrace(points, maxGapSeconds, maxGapMeters) {
+		segment := models.MatchSegment{Indexes: indexes}
+		if len(indexes) < 2 {
+			// A lone point cannot be matched
+			segments = append(segments, segment)
+			continue
+		}
+
+		var tracePoints []models.TracePoint
+		for _, index := range indexes {
+			tracePoints = append(tracePoints, points[index])
+		}
+
+		match, err := s.GetAggregatedMatch(tracePoints, options)
+		if err != nil {
+			log.Printf("Error matching trace segment %d-%d: %v", indexes[0], indexes[len(indexes)-1], err)
+		}
+		segment.Response = match
+		segments = append(segments, segment)
+	}
+
+	log.Printf("Map matching execution time: %v", time.Since(startTime))
+	return segments
+}
+
+// GetAggregatedMatch matches one continuous piece of trace with the healthiest engine
+func (s *RouteAggregatorService) GetAggregatedMatch(points []models.TracePoint, options map[string]string) (*osrm.MatchResponse, error) {
+	var match *osrm.MatchResponse
+	_, err := s.withEngine(options, func(engine routing.RoutingEngine) (err error) {
+		match, err = engine.Match(points, options)
+		return err
+	})
+	return match, err
+}
+
+// splitTrace groups point indexes into continuous pieces of at most maxMatchChunk points,
+// consecutive chunks share their boundary point so no distance is lost between them
+func (s *RouteAggregatorService) splitTrace(points []models.TracePoint, maxGapSeconds, maxGapMeters float64) [][]int {
+	var pieces [][]int
+	var current []int
+
+	for i := range points {
+		if i > 0 {
+			previous, point := points[i-1], points[i]
+			gapSeconds := float64(point.Timestamp - previous.Timestamp)
+			gapMeters := s.TrafficOptimizer.CalculateDistance([]float64{previous.Lon, previous.Lat}, []float64{point.Lon, point.Lat})
+			if gapSeconds > maxGapSeconds || gapMeters > maxGapMeters {
+				pieces = append(pieces, current)
+				current = nil
+			} else if len(current) == maxMatchChunk {
+				pieces = append(pieces, current)
+				current = []int{i - 1}
+			}
+		}
+		current = append(current, i)
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, current)
+	}
+
+	return pieces
+}
@@ -1,18 +1,21 @@
 package services
 
 import (
-	"WayPointPro/internal/config"
+	"WayPointPro/pkg/eta"
 	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/routing"
 	"WayPointPro/pkg/traffic"
 	"WayPointPro/pkg/valhalla"
 	"fmt"
 	"log"
-	"strconv"
-	"strings"
 	"time"
 )
 
 type RouteAggregatorService struct {
+	// Engines serve routes, matrices, snapping and matching, the concrete clients remain for
+	// trips and isochrones which only one engine offers each
+	Engines          *routing.Registry
+	Monitor          *EngineMonitor
 	OSRMService      *osrm.OSRMService
 	ValhallaService  *valhalla.ValhallaService
 	TrafficService   *traffic.Service
@@ -20,126 +23,229 @@ type RouteAggregatorService struct {
 }
 
 func NewRouteAggregatorService(trafficService *traffic.Service) *RouteAggregatorService {
+	return NewRouteAggregatorServiceWithEngines(trafficService, SharedEngineMonitor())
+}
+
+// NewRouteAggregatorServiceWithEngines routes with the engines of the monitor's registry, in the monitor's failover order
+func NewRouteAggregatorServiceWithEngines(trafficService *traffic.Service, monitor *EngineMonitor) *RouteAggregatorService {
 	return &RouteAggregatorService{
-		OSRMService:     osrm.NewOSRMService(),
-		ValhallaService: valhalla.NewValhallaService(),
-		TrafficService:  trafficService,
+		Engines:          monitor.Registry(),
+		Monitor:          monitor,
+		OSRMService:      osrm.NewOSRMService(),
+		ValhallaService:  valhalla.NewValhallaService(),
+		TrafficService:   trafficService,
+		TrafficOptimizer: traffic.NewOptimizer(),
 	}
 }
-func (s *RouteAggregatorService) GetAggregatedRoute(coordinates string, options map[string]string) (*osrm.RouteResponse, error) {
-	if config.LoadConfig().PLATFORM == "OSRM" {
-		return s.GetOSRMService(coordinates, options)
-	} else {
-		return s.GetValhallaService(coordinates, options)
+
+// withEngine runs a call on the healthiest engine and fails over to the next one when the engine
+// is unreachable, answers with a server error or is already known to be down. Request errors are
+// returned as they are, another engine would reject the request too. options["engine"] forces a
+// single engine. It returns the engine that answered
+func (s *RouteAggregatorService) withEngine(options map[string]string, call func(engine routing.RoutingEngine) error) (string, error) {
+	var lastErr error
+	for _, name := range s.Monitor.Candidates(options["engine"]) {
+		engine, err := s.Engines.Get(name)
+		if err != nil {
+			return "", err
+		}
+		if err := call(engine); err != nil {
+			if !routing.Unavailable(err) && s.Monitor.Healthy(name) {
+				return "", err
+			}
+			log.Printf("Routing engine %s failed: %v", name, err)
+			lastErr = err
+			continue
+		}
+		return name, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no routing engine available")
 	}
+	return "", lastErr
 }
-func (s *RouteAggregatorService) GetValhallaService(coordinates string, options map[string]string) (*osrm.RouteResponse, error) {
-	startTime := time.Now()
 
-	//log.Printf("coordinates: %v", coordinates)
-	//log.Printf("options: %v", options)
-	coordinatesList, err := convertCoordinatesToValhalla(coordinates)
+func (s *RouteAggregatorService) GetAggregatedRoute(coordinates string, options map[string]string) (*osrm.RouteResponse, error) {
+	var route *osrm.RouteResponse
+	name, err := s.withEngine(options, func(engine routing.RoutingEngine) error {
+		startTime := time.Now()
+		response, err := engine.Route(coordinates, options)
+		log.Printf("Routing API execution time (%s): %v", engine.Name(), time.Since(startTime))
+		if err != nil {
+			return err
+		}
+		// Engines without their own traffic model are timed with the traffic tiles
+		if response.Traffic == nil {
+			if err := s.applyTraffic(response, options); err != nil {
+				return err
+			}
+		}
+		route = response
+		return nil
+	})
 	if err != nil {
-		log.Printf("Error coordinates: %v", err)
 		return nil, err
 	}
 
-	route, err := s.ValhallaService.GetRoute(coordinatesList, options)
+	// Calibrated durations only depend on the route and the departure bucket
+	departure, _, err := routeDeparture(route.Routes[0], options)
 	if err != nil {
-		log.Printf("Error fetching route: %v", err)
 		return nil, err
 	}
+	eta.SharedCalibrator().Apply(route, departure)
 
-	duration := time.Since(startTime)
-	log.Printf("Routing API execution time: %v", duration)
-
-	if route == nil || len(route.Trip.Legs) == 0 {
-		return nil, fmt.Errorf("invalid route")
-	}
-	startTime = time.Now()
-	validRoute, err := s.OSRMService.ConvertToOSRM(route)
-	duration = time.Since(startTime)
-	log.Printf("Convert modeling API execution time: %v", duration)
-	return validRoute, nil
+	route.Engine = name
+	return route, nil
 }
 
-func (s *RouteAggregatorService) GetOSRMService(coordinates string, options map[string]string) (*osrm.RouteResponse, error) {
-	startTime := time.Now()
-
-	//log.Printf("coordinates: %v", coordinates)
-	//log.Printf("options: %v", options)
-
-	route, err := s.OSRMService.GetRoute(coordinates, options)
-	if err != nil {
-		log.Printf("Error fetching OSRM route: %v", err)
-		return nil, err
-	}
-
-	duration := time.Since(startTime)
-	log.Printf("OSRM API execution time: %v", duration)
-
+// applyTraffic sets the traffic duration of every route from the live or stored traffic tiles
+func (s *RouteAggregatorService) applyTraffic(route *osrm.RouteResponse, options map[string]string) error {
 	useTraffic := false
 	for key, value := range options {
 		if key == "traffic" && value == "true" {
 			useTraffic = true
 		}
 	}
+	// Road traffic does not slow down cyclists and pedestrians
+	if options["mode"] == ModeBicycle || options["mode"] == ModePedestrian {
+		useTraffic = false
+	}
 
-	if route == nil || len(route.Routes) == 0 {
-		return nil, fmt.Errorf("invalid route")
+	route.Traffic = &osrm.TrafficSnapshot{Source: "none", DepartAt: options["depart_at"], ArriveBy: options["arrive_by"]}
+	departure, scheduled, err := routeDeparture(route.Routes[0], options)
+	if err != nil {
+		return err
 	}
 
 	if useTraffic {
-		// 1. Fetch bounding box
-		geometry := route.Routes[0].Geometry.Coordinates
+		// 1. Fetch bounding box covering the route and its alternatives
+		var geometry [][]float64
+		for _, alternative := range route.Routes {
+			geometry = append(geometry, alternative.Geometry.Coordinates...)
+		}
 		boundingBox := s.TrafficOptimizer.GetBoundingBox(geometry)
 
-		// 2. Fetch traffic data
+		// 2. Fetch traffic data, live for now and the stored bucket of the departure time for later trips
 		trafficStartTime := time.Now()
-		trafficData, err := s.TrafficService.FetchAndAnalyzeTraffic(boundingBox, 11, false)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch traffic data: %v", err)
+		var trafficData []traffic.TrafficFeature
+		if scheduled {
+			trafficData = s.TrafficService.FetchStoredTraffic(boundingBox, traffic.DefaultTrafficZoom, departure)
+			route.Traffic.Source = "historical"
+		} else {
+			var tiles traffic.TileSources
+			trafficData, tiles, err = s.TrafficService.FetchAndAnalyzeTraffic(boundingBox, traffic.DefaultTrafficZoom, false)
+			if err != nil {
+				// Nothing was fetched, the speed profiles below time the route and the source says so
+				log.Printf("Failed to fetch live traffic data: %v", err)
+				trafficData = nil
+			} else {
+				// Tiles the provider could not serve were timed with their speed profile
+				route.Traffic.Source = tiles.Source()
+				route.Traffic.Tiles = tiles
+			}
+		}
+		route.Traffic.Bucket = traffic.TrafficBucketLabel(departure)
+		// Without tiles for the bucket the typical traffic of the speed profiles is the best estimate
+		if len(trafficData) == 0 {
+			log.Printf("No traffic tiles for bucket %s, using speed profiles", route.Traffic.Bucket)
+			trafficData = s.TrafficService.FetchProfileTraffic(boundingBox, traffic.DefaultTrafficZoom, departure)
+			route.Traffic.Source = "profile"
+			route.Traffic.Tiles = nil
+			if len(trafficData) == 0 {
+				route.Traffic.Source = "none"
+				route.Traffic.Bucket = ""
+			}
 		}
 		log.Printf("Execution Time for fetching traffic: %v seconds", time.Since(trafficStartTime).Seconds())
 
-		// 3. Adjust route based on traffic data
+		// 3. Adjust every route based on traffic data
 		adjustStartTime := time.Now()
-		adjustedRoute := s.TrafficOptimizer.AdjustRouteTime(route.Routes[0], trafficData)
+		for i := range route.Routes {
+			adjustedRoute := s.TrafficOptimizer.AdjustRouteTime(route.Routes[i], trafficData)
+			// 4. Update route duration
+			route.Routes[i].TrafficDuration = adjustedRoute.TrafficDuration
+		}
 		log.Printf("Execution Time for analyze traffic: %v seconds", time.Since(adjustStartTime).Seconds())
-
-		// 4. Update route duration
-		route.Routes[0].TrafficDuration = adjustedRoute.TrafficDuration
 	} else {
-		route.Routes[0].TrafficDuration = route.Routes[0].Duration
+		for i := range route.Routes {
+			route.Routes[i].TrafficDuration = route.Routes[i].Duration
+		}
 	}
 
-	return route, nil
+	return nil
 }
 
-// Function to convert coordinate string to Valhalla locations
-func convertCoordinatesToValhalla(coordStr string) ([]valhalla.Location, error) {
-	coords := strings.Split(coordStr, ";") // Split by ';'
-	var locations []valhalla.Location
-
-	for _, coord := range coords {
-		parts := strings.Split(coord, ",") // Split lat/lon
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid coordinate format: %s", coord)
-		}
-
-		lat, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+// routeDeparture returns when the route starts and whether it is scheduled away from now.
+// OSRM has no arrive-by routing, the departure is the arrival minus the free flow duration
+func routeDeparture(route osrm.Route, options map[string]string) (time.Time, bool, error) {
+	departure := time.Now()
+	if value := options["depart_at"]; value != "" {
+		at, err := time.Parse(time.RFC3339, value)
 		if err != nil {
-			return nil, fmt.Errorf("invalid latitude: %s", parts[1])
+			return departure, false, fmt.Errorf("invalid depart_at: %v", err)
 		}
-
-		lon, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		departure = at
+	} else if value := options["arrive_by"]; value != "" {
+		at, err := time.Parse(time.RFC3339, value)
 		if err != nil {
-			return nil, fmt.Errorf("invalid longitude: %s", parts[0])
+			return departure, false, fmt.Errorf("invalid arrive_by: %v", err)
 		}
+		departure = at.Add(-time.Duration(route.Duration) * time.Second)
+	}
+
+	// Within the current bucket the live tiles are the better estimate
+	scheduled := departure.Sub(time.Now()).Abs() > 15*time.Minute
+	return departure, scheduled, nil
+}
+
+func (s *RouteAggregatorService) GetAggregatedMatrix(sources, destinations string, options map[string]string) (*osrm.TableResponse, error) {
+	startTime := time.Now()
+	defer func() {
+		log.Printf("Matrix API execution time: %v", time.Since(startTime))
+	}()
+
+	var table *osrm.TableResponse
+	name, err := s.withEngine(options, func(engine routing.RoutingEngine) (err error) {
+		table, err = engine.Matrix(sources, destinations, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	table.Engine = name
+	return table, nil
+}
+
+// GetAggregatedIsochrone returns reachability contours around a location, only Valhalla supports isochrones
+func (s *RouteAggregatorService) GetAggregatedIsochrone(location string, contours []valhalla.Contour, options map[string]string) (*valhalla.IsochroneResponse, error) {
+	startTime := time.Now()
 
-		// Append to locations with "break" type (waypoints)
-		locations = append(locations, valhalla.Location{Lat: lat, Lon: lon, Type: "break"})
+	locations, err := routing.ValhallaLocations(location)
+	if err != nil || len(locations) != 1 {
+		return nil, fmt.Errorf("invalid location: %s", location)
 	}
 
-	return locations, nil
+	isochrone, err := s.ValhallaService.GetIsochrone(locations[0], contours, options)
+	if err != nil {
+		log.Printf("Error fetching isochrone: %v", err)
+		return nil, err
+	}
+
+	log.Printf("Isochrone API execution time: %v", time.Since(startTime))
+	return isochrone, nil
+}
+
+// GetAggregatedNearest snaps every coordinate to the closest road, points that cannot be snapped are nil
+func (s *RouteAggregatorService) GetAggregatedNearest(coordinates string, options map[string]string) ([]*osrm.Waypoint, error) {
+	startTime := time.Now()
+	defer func() {
+		log.Printf("Nearest API execution time: %v", time.Since(startTime))
+	}()
+
+	var waypoints []*osrm.Waypoint
+	_, err := s.withEngine(options, func(engine routing.RoutingEngine) (err error) {
+		waypoints, err = engine.Nearest(coordinates, options)
+		return err
+	})
+	return waypoints, err
 }
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"WayPointPro/pkg/routing"
+	"WayPointPro/pkg/valhalla"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestWithEngineFailover(t *testing.T) {
+	invalid := errors.New("osrm table error: InvalidQuery Query string malformed")
+	tests := []struct {
+		name       string
+		primaryErr error
+		// primaryDown marks the primary engine down before the call
+		primaryDown bool
+		engine      string
+		wantErr     error
+	}{
+		{name: "transport error fails over", primaryErr: &url.Error{Op: "Post", URL: "http://valhalla", Err: errors.New("connection refused")}, engine: routing.EngineOSRM},
+		{name: "server error fails over", primaryErr: &valhalla.ServerError{StatusCode: http.StatusServiceUnavailable}, engine: routing.EngineOSRM},
+		{name: "request error is returned as is", primaryErr: invalid, wantErr: invalid},
+		{name: "engine known to be down fails over", primaryErr: invalid, primaryDown: true, engine: routing.EngineOSRM},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := routing.NewFakeEngine(routing.EngineValhalla)
+			primary.Err = tt.primaryErr
+			secondary := routing.NewFakeEngine(routing.EngineOSRM)
+			monitor := NewEngineMonitor(routing.NewRegistryOf(primary, secondary))
+			if tt.primaryDown {
+				// Both engines down keeps the primary first in line
+				primary.HealthErr = errors.New("down")
+				secondary.HealthErr = errors.New("down")
+				for i := 0; i < engineFailureThreshold; i++ {
+					monitor.Probe()
+				}
+			}
+			service := NewRouteAggregatorServiceWithEngines(nil, monitor)
+
+			calls := 0
+			name, err := service.withEngine(map[string]string{}, func(engine routing.RoutingEngine) error {
+				calls++
+				_, err := engine.Matrix("46.67,24.71", "46.7,24.75", nil)
+				return err
+			})
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if name != tt.engine {
+				t.Errorf("answered by %q, want %q", name, tt.engine)
+			}
+			if tt.wantErr != nil && calls != 1 {
+				t.Errorf("%d engines called, want the failing one only", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package services
+
+import "WayPointPro/pkg/osrm"
+
+// Approximate meters per degree, good enough to turn a tolerance into degrees
+const metersPerDegree = 111320.0
+
+// SimplifyRoute thins the route, alternative, leg and step lines with Douglas-Peucker, tolerance is in meters
+func (s *RouteAggregatorService) SimplifyRoute(route *osrm.RouteResponse, tolerance float64) {
+	if tolerance <= 0 {
+		return
+	}
+	degrees := tolerance / metersPerDegree
+
+	for i := range route.Routes {
+		route.Routes[i].Geometry.Coordinates = s.TrafficOptimizer.SimplifyRoute(route.Routes[i].Geometry.Coordinates, degrees)
+		for j := range route.Routes[i].Legs {
+			if geometry := route.Routes[i].Legs[j].Geometry; geometry != nil {
+				geometry.Coordinates = s.TrafficOptimizer.SimplifyRoute(geometry.Coordinates, degrees)
+			}
+			for _, step := range route.Routes[i].Legs[j].Steps {
+				if step.Geometry != nil {
+					step.Geometry.Coordinates = s.TrafficOptimizer.SimplifyRoute(step.Geometry.Coordinates, degrees)
+				}
+			}
+		}
+	}
+}
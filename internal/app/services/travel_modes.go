@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Travel modes accepted in route requests
+const (
+	ModeCar        = "car"
+	ModeTruck      = "truck"
+	ModeMotorcycle = "motorcycle"
+	ModeBicycle    = "bicycle"
+	ModePedestrian = "pedestrian"
+)
+
+// Valhalla costing model of every travel mode
+var valhallaCostings = map[string]string{
+	ModeCar:        "auto",
+	ModeTruck:      "truck",
+	ModeMotorcycle: "motorcycle",
+	ModeBicycle:    "bicycle",
+	ModePedestrian: "pedestrian",
+}
+
+// TruckOptions are the vehicle limits of the truck mode, dimensions in meters and weights in metric tons
+type TruckOptions struct {
+	Height   float64 `json:"height"`
+	Width    float64 `json:"width"`
+	Weight   float64 `json:"weight"`
+	AxleLoad float64 `json:"axle_load"`
+	Hazmat   bool    `json:"hazmat"`
+}
+
+// ValidateTravelMode checks the mode is supported and its parameters are in a sane range
+func ValidateTravelMode(mode string, truck *TruckOptions) error {
+	if _, ok := valhallaCostings[mode]; !ok {
+		return fmt.Errorf("invalid mode %q, expected car, truck, motorcycle, bicycle or pedestrian", mode)
+	}
+	if truck == nil {
+		return nil
+	}
+	if mode != ModeTruck {
+		return fmt.Errorf("truck parameters are only allowed with mode truck")
+	}
+
+	limits := []struct {
+		name  string
+		value float64
+		max   float64
+	}{
+		{"height", truck.Height, 10},
+		{"width", truck.Width, 5},
+		{"weight", truck.Weight, 100},
+		{"axle_load", truck.AxleLoad, 40},
+	}
+	for _, limit := range limits {
+		if limit.value < 0 || limit.value > limit.max {
+			return fmt.Errorf("truck %s must be between 0 and %v", limit.name, limit.max)
+		}
+	}
+	if truck.AxleLoad > 0 && truck.Weight > 0 && truck.AxleLoad > truck.Weight {
+		return fmt.Errorf("truck axle_load cannot exceed weight")
+	}
+	return nil
+}
+
+// TravelModeOptions returns the route options selecting the mode on both engines
+func TravelModeOptions(mode string, truck *TruckOptions) map[string]string {
+	options := map[string]string{
+		"mode":    mode,
+		"costing": valhallaCostings[mode],
+	}
+	if truck != nil {
+		options["truck_height"] = strconv.FormatFloat(truck.Height, 'f', -1, 64)
+		options["truck_width"] = strconv.FormatFloat(truck.Width, 'f', -1, 64)
+		options["truck_weight"] = strconv.FormatFloat(truck.Weight, 'f', -1, 64)
+		options["truck_axle_load"] = strconv.FormatFloat(truck.AxleLoad, 'f', -1, 64)
+		options["truck_hazmat"] = strconv.FormatBool(truck.Hazmat)
+	}
+	return options
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"WayPointPro/internal/config"
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/routing"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// GetOptimizedTrip finds the best visiting order for the stops and returns the full route through them.
+// The returned order holds indexes into stops in visiting order; start and end are optional fixed points.
+func (s *RouteAggregatorService) GetOptimizedTrip(stops []string, start, end string, roundtrip bool, options map[string]string) (*osrm.RouteResponse, []int, error) {
+	startTime := time.Now()
+
+	// Build the full point list: [start] + stops + [end]
+	var points []string
+	if start != "" {
+		points = append(points, start)
+	}
+	points = append(points, stops...)
+	if end != "" {
+		points = append(points, end)
+	}
+
+	path, err := s.getOptimizedPath(points, start != "", end != "", roundtrip, options)
+	if err != nil {
+		log.Printf("Error optimizing trip: %v", err)
+		return nil, nil, err
+	}
+	log.Printf("Trip optimization execution time: %v", time.Since(startTime))
+
+	// Map the path back to stop indexes and build the ordered coordinates
+	var order []int
+	var orderedCoordinates []string
+	for _, index := range path {
+		orderedCoordinates = append(orderedCoordinates, points[index])
+		if start != "" && index == 0 {
+			continue
+		}
+		if end != "" && index == len(points)-1 {
+			continue
+		}
+		if start != "" {
+			index--
+		}
+		order = append(order, index)
+	}
+	if roundtrip {
+		orderedCoordinates = append(orderedCoordinates, points[path[0]])
+	}
+
+	route, err := s.GetAggregatedRoute(strings.Join(orderedCoordinates, ";"), options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return route, order, nil
+}
+
+// getOptimizedPath returns the visiting order as indexes into points
+func (s *RouteAggregatorService) getOptimizedPath(points []string, fixedStart, fixedEnd, roundtrip bool, options map[string]string) ([]int, error) {
+	// Both engines can only optimize an open trip when its first and last points are fixed,
+	// every other case is solved as a round trip which is cut open afterwards
+	openTrip := !roundtrip && fixedStart && fixedEnd
+
+	var order []int
+	var legDurations []float64
+	var err error
+	if config.LoadConfig().PLATFORM == "OSRM" {
+		order, legDurations, err = s.getOSRMTripOrder(points, fixedStart, openTrip)
+	} else {
+		order, legDurations, err = s.getValhallaTripOrder(points, openTrip, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if openTrip || roundtrip || fixedStart {
+		// The tour already starts at the fixed start, the closing leg is simply dropped
+		return order, nil
+	}
+
+	// Cut the tour open by dropping one leg: the one leaving the fixed end, otherwise the longest
+	cut := 0
+	for k := range order {
+		if fixedEnd {
+			if order[k] == len(points)-1 {
+				cut = k
+				break
+			}
+			continue
+		}
+		if k < len(legDurations) && legDurations[k] > legDurations[cut] {
+			cut = k
+		}
+	}
+
+	return append(append([]int{}, order[cut+1:]...), order[:cut+1]...), nil
+}
+
+// getOSRMTripOrder asks OSRM /trip for the visiting order and the leg durations of the tour
+func (s *RouteAggregatorService) getOSRMTripOrder(points []string, fixedStart, openTrip bool) ([]int, []float64, error) {
+	tripOptions := map[string]string{
+		"roundtrip": "true",
+		"source":    "any",
+	}
+	if fixedStart {
+		tripOptions["source"] = "first"
+	}
+	if openTrip {
+		tripOptions["roundtrip"] = "false"
+		tripOptions["destination"] = "last"
+	}
+
+	trip, err := s.OSRMService.GetTrip(strings.Join(points, ";"), tripOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(trip.Trips) != 1 || len(trip.Waypoints) != len(points) {
+		return nil, nil, fmt.Errorf("stops could not be connected in a single trip")
+	}
+
+	order := make([]int, len(points))
+	for inputIndex, waypoint := range trip.Waypoints {
+		order[waypoint.WaypointIndex] = inputIndex
+	}
+
+	var legDurations []float64
+	for _, leg := range trip.Trips[0].Legs {
+		legDurations = append(legDurations, leg.Duration)
+	}
+
+	return order, legDurations, nil
+}
+
+// getValhallaTripOrder asks Valhalla optimized_route for the visiting order and the leg durations of the tour
+func (s *RouteAggregatorService) getValhallaTripOrder(points []string, openTrip bool, options map[string]string) ([]int, []float64, error) {
+	locations, err := routing.ValhallaLocations(strings.Join(points, ";"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Valhalla keeps the first and last locations fixed, closing the loop on the first point makes it a tour
+	if !openTrip {
+		locations = append(locations, locations[0])
+	}
+
+	route, err := s.ValhallaService.GetOptimizedRoute(locations, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(route.Trip.Locations) != len(locations) {
+		return nil, nil, fmt.Errorf("invalid optimized route")
+	}
+
+	order := make([]int, len(points))
+	for i := range points {
+		order[i] = route.Trip.Locations[i].OriginalIndex
+	}
+
+	var legDurations []float64
+	for _, leg := range route.Trip.Legs {
+		legDurations = append(legDurations, leg.Summary.Time)
+	}
+
+	return order, legDurations, nil
+}
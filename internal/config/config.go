@@ -11,14 +11,26 @@ import (
 type Config struct {
 	ValhallaHost string
 	OSRMHost     string
-	Port         string
-	DBHost       string
-	DBPort       string
-	DBUser       string
-	DBPassword   string
-	DBName       string
-	REDIS        string
-	PLATFORM     string
+	// OSRM instances of the other travel profiles, empty when the profile is not deployed
+	OSRMTruckHost      string
+	OSRMMotorcycleHost string
+	OSRMBicycleHost    string
+	OSRMPedestrianHost string
+	Port               string
+	DBHost             string
+	DBPort             string
+	DBUser             string
+	DBPassword         string
+	DBName             string
+	REDIS              string
+	PLATFORM           string
+	// Decimal places of the coordinates in route cache keys, and the key namespace to bump on deploys
+	RouteCachePrecision string
+	RouteCacheVersion   string
+	// Traffic provider of every region, e.g. "default=mapbox,riyadh=tomtom"
+	TrafficProviders string
+	// Bounding boxes of the traffic provider regions as west,south,east,north, e.g. "riyadh=46.3,24.3,47.2,25.1"
+	TrafficProviderRegions string
 }
 
 var (
@@ -33,16 +45,24 @@ func LoadConfig() *Config {
 		}
 
 		config := &Config{
-			ValhallaHost: getEnv("VALHALLA_HOST", ""),
-			OSRMHost:     getEnv("OSRM_HOST", ""),
-			Port:         getEnv("PORT", ""),
-			DBHost:       getEnv("DB_HOST", ""),
-			DBUser:       getEnv("DB_USER", ""),
-			DBPassword:   getEnv("DB_PASSWORD", ""),
-			DBName:       getEnv("DB_NAME", ""),
-			DBPort:       getEnv("DB_PORT", "5432"),
-			REDIS:        getEnv("REDIS", ""),
-			PLATFORM:     getEnv("PLATFORM", ""),
+			ValhallaHost:           getEnv("VALHALLA_HOST", ""),
+			OSRMHost:               getEnv("OSRM_HOST", ""),
+			OSRMTruckHost:          getEnv("OSRM_TRUCK_HOST", ""),
+			OSRMMotorcycleHost:     getEnv("OSRM_MOTORCYCLE_HOST", ""),
+			OSRMBicycleHost:        getEnv("OSRM_BICYCLE_HOST", ""),
+			OSRMPedestrianHost:     getEnv("OSRM_PEDESTRIAN_HOST", ""),
+			Port:                   getEnv("PORT", ""),
+			DBHost:                 getEnv("DB_HOST", ""),
+			DBUser:                 getEnv("DB_USER", ""),
+			DBPassword:             getEnv("DB_PASSWORD", ""),
+			DBName:                 getEnv("DB_NAME", ""),
+			DBPort:                 getEnv("DB_PORT", "5432"),
+			REDIS:                  getEnv("REDIS", ""),
+			PLATFORM:               getEnv("PLATFORM", ""),
+			RouteCachePrecision:    getEnv("ROUTE_CACHE_PRECISION", "5"),
+			RouteCacheVersion:      getEnv("ROUTE_CACHE_VERSION", "v1"),
+			TrafficProviders:       getEnv("TRAFFIC_PROVIDERS", "default=mapbox"),
+			TrafficProviderRegions: getEnv("TRAFFIC_PROVIDER_REGIONS", ""),
 		}
 		instance = config
 	})
@@ -3,19 +3,23 @@ package map_service
 import (
 	"WayPointPro/internal/app/services"
 	"WayPointPro/internal/models"
-	"WayPointPro/pkg/traffic"
+	"WayPointPro/pkg/eta"
+	"WayPointPro/pkg/routing"
 	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // GetRouteHandler handles requests for route information
 func GetRouteHandler(c *gin.Context) {
 
-	trafficService := traffic.NewService()
-	aggregator := services.NewRouteAggregatorService(trafficService)
+	trafficService := newTrafficService()
+	aggregator := newAggregator(trafficService)
 	// Ensure the request method is POST
 	if c.Request.Method != http.MethodPost {
 		c.JSON(http.StatusMethodNotAllowed, gin.H{"status": false, "message": "Method not allowed"})
@@ -28,6 +32,28 @@ func GetRouteHandler(c *gin.Context) {
 		Legs        string `json:"legs"`
 		Traffic     string `json:"traffic"`
 		Alternates  string `json:"alternates"`
+		// Travel mode, car when empty, truck limits only apply to the truck mode
+		Mode  string                 `json:"mode"`
+		Truck *services.TruckOptions `json:"truck"`
+		// RFC 3339 times in the local offset of the trip, at most one of them
+		DepartAt string `json:"depart_at"`
+		ArriveBy string `json:"arrive_by"`
+		// Language of the instructions and texts, e.g. ar or en
+		Lang string `json:"lang"`
+		// Output format, json by default or geojson, gpx and kml
+		Format string `json:"format"`
+		// Geometry encoding, geojson by default or polyline and polyline6, simplify is a tolerance in meters
+		Geometries string  `json:"geometries"`
+		Simplify   float64 `json:"simplify"`
+		// Forces a registered engine such as osrm or valhalla, the healthy engine is picked when empty
+		Engine string `json:"engine"`
+		// Avoidance preferences, custom areas are polygons of [lon, lat] rings
+		AvoidTolls      string        `json:"avoid_tolls"`
+		AvoidHighways   string        `json:"avoid_highways"`
+		AvoidFerries    string        `json:"avoid_ferries"`
+		ExcludePolygons [][][]float64 `json:"exclude_polygons"`
+		// Optional per-coordinate type, bearing, radius, side of street, OSRM approach and hint
+		Waypoints []routing.WaypointOptions `json:"waypoints"`
 	}
 	if err := json.NewDecoder(c.Request.Body).Decode(&requestBody); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid JSON body"})
@@ -40,6 +66,50 @@ func GetRouteHandler(c *gin.Context) {
 		return
 	}
 
+	if requestBody.Mode == "" {
+		requestBody.Mode = services.ModeCar
+	}
+	if err := services.ValidateTravelMode(requestBody.Mode, requestBody.Truck); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": err.Error()})
+		return
+	}
+
+	if requestBody.Engine != "" {
+		if _, err := aggregator.Engines.Get(requestBody.Engine); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": err.Error()})
+			return
+		}
+	}
+
+	if requestBody.Format != "" && requestBody.Format != models.FormatJSON && !models.IsExportFormat(requestBody.Format) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid 'format', expected json, geojson, gpx or kml"})
+		return
+	}
+
+	if requestBody.Geometries != "" && !models.IsGeometryFormat(requestBody.Geometries) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid 'geometries', expected polyline, polyline6 or geojson"})
+		return
+	}
+	if requestBody.Simplify < 0 || requestBody.Simplify > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "'simplify' must be between 0 and 1000 meters"})
+		return
+	}
+
+	if err := validateTripTime(requestBody.DepartAt, requestBody.ArriveBy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": err.Error()})
+		return
+	}
+
+	if err := validateExcludePolygons(requestBody.ExcludePolygons); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": err.Error()})
+		return
+	}
+
+	if err := routing.ValidateWaypoints(requestBody.Waypoints, len(strings.Split(requestBody.Coordinates, ";"))); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": err.Error()})
+		return
+	}
+
 	// Log received data
 	//log.Printf("Coordinates: %s, Legs: %s", requestBody.Coordinates, requestBody.Legs)
 
@@ -51,16 +121,42 @@ func GetRouteHandler(c *gin.Context) {
 
 	// Options for the route
 	options := map[string]string{
-		"overview":   "full",
-		"geometries": "geojson",
-		"steps":      legs,
-		"traffic":    requestBody.Traffic,
-		"costing":    "auto",
-		"alternates": requestBody.Alternates,
+		"overview":            "full",
+		"geometries":          "geojson",
+		"steps":               legs,
+		"traffic":             requestBody.Traffic,
+		"alternates":          requestBody.Alternates,
+		routing.AvoidTolls:    requestBody.AvoidTolls,
+		routing.AvoidHighways: requestBody.AvoidHighways,
+		routing.AvoidFerries:  requestBody.AvoidFerries,
+		"depart_at":           requestBody.DepartAt,
+		"arrive_by":           requestBody.ArriveBy,
+		"engine":              requestBody.Engine,
+		"lang":                requestBody.Lang,
+	}
+	for key, value := range services.TravelModeOptions(requestBody.Mode, requestBody.Truck) {
+		options[key] = value
+	}
+	if len(requestBody.ExcludePolygons) > 0 {
+		polygons, _ := json.Marshal(requestBody.ExcludePolygons)
+		options[routing.ExcludePolygons] = string(polygons)
+	}
+	if len(requestBody.Waypoints) > 0 {
+		waypoints, _ := json.Marshal(requestBody.Waypoints)
+		options[routing.WaypointOptionsKey] = string(waypoints)
+	}
+
+	// The output geometry only changes the response, it is part of the cache key but not of the engine options
+	keyOptions := map[string]string{
+		"output_geometries": requestBody.Geometries,
+		"simplify":          strconv.FormatFloat(requestBody.Simplify, 'f', -1, 64),
+	}
+	for key, value := range options {
+		keyOptions[key] = value
 	}
 
 	// Generate a unique cached_key
-	cachedKey := trafficService.Cache.GenerateRouteCacheKey(requestBody.Coordinates)
+	cachedKey := trafficService.Cache.GenerateRouteCacheKey(requestBody.Coordinates, keyOptions)
 	log.Printf("cachedKey: %s", cachedKey)
 	// Check Redis cache
 	cachedData, err := trafficService.Cache.GetFromRedis(cachedKey)
@@ -71,7 +167,7 @@ func GetRouteHandler(c *gin.Context) {
 		if err != nil {
 			return
 		}
-		c.JSON(http.StatusOK, cachedRoute)
+		writeRoute(c, cachedRoute, requestBody.Format)
 		return
 	}
 
@@ -82,10 +178,64 @@ func GetRouteHandler(c *gin.Context) {
 	}
 
 	startTime := time.Now()
-	response := models.TransformRoute(route)
+	aggregator.SimplifyRoute(route, requestBody.Simplify)
+	response := models.TransformRoute(route, requestBody.Lang)
+	response.EncodeGeometry(requestBody.Geometries)
+	// The cache key doubles as the id of the stored route for GET /api/route/:id/export
+	response.ID = cachedKey
 	trafficService.Cache.CacheRouteResponse(cachedKey, response)
+	// The quote outlives the cached route so trips reported hours later still find their ETA
+	if err := eta.SaveQuote(routeQuote(response)); err != nil {
+		log.Printf("Failed to store route quote %s: %v", cachedKey, err)
+	}
 	duration := time.Since(startTime)
 	log.Printf("response modeling API execution time: %v", duration)
-	c.JSON(http.StatusOK, response)
+	writeRoute(c, response, requestBody.Format)
 
 }
+
+// writeRoute responds with the route as JSON or rendered in an export format
+func writeRoute(c *gin.Context, route models.TransformedRoute, format string) {
+	if format == "" || format == models.FormatJSON {
+		c.JSON(http.StatusOK, route)
+		return
+	}
+
+	body, contentType, err := models.RenderRoute(route, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": false, "message": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// validateExcludePolygons checks every custom area is a ring of at least three [lon, lat] points
+func validateExcludePolygons(polygons [][][]float64) error {
+	for i, polygon := range polygons {
+		if len(polygon) < 3 {
+			return fmt.Errorf("exclude_polygons[%d] needs at least 3 points", i)
+		}
+		for _, point := range polygon {
+			if len(point) != 2 || point[0] < -180 || point[0] > 180 || point[1] < -90 || point[1] > 90 {
+				return fmt.Errorf("exclude_polygons[%d] has an invalid [lon, lat] point", i)
+			}
+		}
+	}
+	return nil
+}
+
+// validateTripTime checks depart_at and arrive_by are RFC 3339 times and not both set
+func validateTripTime(departAt, arriveBy string) error {
+	if departAt != "" && arriveBy != "" {
+		return fmt.Errorf("set either depart_at or arrive_by, not both")
+	}
+	for name, value := range map[string]string{"depart_at": departAt, "arrive_by": arriveBy} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("invalid %s, expected RFC 3339 such as 2025-01-31T08:30:00+03:00", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package map_service
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/routing"
+	"WayPointPro/pkg/valhalla"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveRoute(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	useTrafficService(t)
+	return serve(t, "/api/route", GetRouteHandler, body)
+}
+
+func TestGetRouteHandler(t *testing.T) {
+	engineDown := &valhalla.ServerError{StatusCode: http.StatusServiceUnavailable, Message: "valhalla is down"}
+	tests := []struct {
+		name       string
+		primaryErr error
+		body       string
+		engine     string
+	}{
+		{
+			name:   "primary engine answers",
+			body:   `{"coordinates": "46.6753,24.7136;46.7,24.75"}`,
+			engine: routing.EngineValhalla,
+		},
+		{
+			name:       "fails over when the primary engine is down",
+			primaryErr: engineDown,
+			body:       `{"coordinates": "46.6753,24.7136;46.7,24.75"}`,
+			engine:     routing.EngineOSRM,
+		},
+		{
+			name:   "forced engine skips the primary",
+			body:   `{"coordinates": "46.6753,24.7136;46.7,24.75", "engine": "osrm"}`,
+			engine: routing.EngineOSRM,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := routing.NewFakeEngine(routing.EngineValhalla)
+			primary.Err = tt.primaryErr
+			useEngines(t, primary, routing.NewFakeEngine(routing.EngineOSRM))
+
+			recorder := serveRoute(t, tt.body)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+			}
+			var response models.TransformedRoute
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if !response.Status || response.Engine != tt.engine {
+				t.Errorf("status %v from engine %q, want true from %q", response.Status, response.Engine, tt.engine)
+			}
+			// About 4.7 km between the two points, in kilometers whichever engine answered
+			if response.Distance.Value < 4 || response.Distance.Value > 6 {
+				t.Errorf("distance = %v km, want about 4.7", response.Distance.Value)
+			}
+		})
+	}
+}
+
+func TestGetRouteHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		primaryErr error
+		body       string
+		code       int
+	}{
+		{"unknown engine", nil, `{"coordinates": "46.6753,24.7136;46.7,24.75", "engine": "graphhopper"}`, http.StatusBadRequest},
+		{"forced engine down", &valhalla.ServerError{StatusCode: http.StatusBadGateway}, `{"coordinates": "46.6753,24.7136;46.7,24.75", "engine": "valhalla"}`, http.StatusInternalServerError},
+		// A rejected request would be rejected by the next engine too
+		{"request error does not fail over", errors.New("valhalla error 171: No suitable edges near location"), `{"coordinates": "46.6753,24.7136;46.7,24.75"}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := routing.NewFakeEngine(routing.EngineValhalla)
+			primary.Err = tt.primaryErr
+			useEngines(t, primary, routing.NewFakeEngine(routing.EngineOSRM))
+
+			recorder := serveRoute(t, tt.body)
+			if recorder.Code != tt.code {
+				t.Errorf("status = %d, want %d, body %s", recorder.Code, tt.code, recorder.Body)
+			}
+		})
+	}
+}
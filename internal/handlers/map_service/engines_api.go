@@ -0,0 +1,32 @@
+package map_service
+
+import (
+	"WayPointPro/internal/app/services"
+	"WayPointPro/pkg/traffic"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// engineMonitor returns the routing engines the handlers use in failover order,
+// tests replace it with a monitor of a registry of fake engines
+var engineMonitor = services.SharedEngineMonitor
+
+// newTrafficService returns the traffic service and cache of a request, tests replace it to keep off Redis
+var newTrafficService = traffic.NewService
+
+// newAggregator returns the route aggregator of a request on the handlers' engines
+func newAggregator(trafficService *traffic.Service) *services.RouteAggregatorService {
+	return services.NewRouteAggregatorServiceWithEngines(trafficService, engineMonitor())
+}
+
+// GetEnginesHandler returns the health of the routing engines and the failover order
+func GetEnginesHandler(c *gin.Context) {
+	monitor := engineMonitor()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  true,
+		"message": "Fetched engine state successfully!",
+		"engines": monitor.States(),
+		"order":   monitor.Candidates(""),
+	})
+}
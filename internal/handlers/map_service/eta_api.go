@@ -0,0 +1,57 @@
+package map_service
+
+import (
+	"WayPointPro/pkg/eta"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+)
+
+// GetETACoefficientsHandler returns the ETA calibration regions and coefficients currently in use
+func GetETACoefficientsHandler(c *gin.Context) {
+	regions, coefficients, loadedAt := eta.SharedCalibrator().Snapshot()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       true,
+		"message":      "Fetched ETA coefficients successfully!",
+		"regions":      regions,
+		"coefficients": coefficients,
+		"loaded_at":    loadedAt,
+	})
+}
+
+// GetETAReportHandler returns the fitted ETA corrections with the mean absolute error and bias before and after
+// calibration per region and hour, and over all trips
+func GetETAReportHandler(c *gin.Context) {
+	corrections, err := eta.Report()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": false, "message": "Failed to fetch ETA report"})
+		return
+	}
+
+	var samples int
+	var maeBefore, biasBefore, maeAfter, biasAfter float64
+	for _, correction := range corrections {
+		weight := float64(correction.Samples)
+		samples += correction.Samples
+		maeBefore += correction.MAEBefore * weight
+		biasBefore += correction.BiasBefore * weight
+		maeAfter += correction.MAEAfter * weight
+		biasAfter += correction.BiasAfter * weight
+	}
+	overall := gin.H{"samples": samples}
+	if samples > 0 {
+		total := float64(samples)
+		overall["mae_before"] = math.Round(maeBefore/total*10) / 10
+		overall["bias_before"] = math.Round(biasBefore/total*10) / 10
+		overall["mae_after"] = math.Round(maeAfter/total*10) / 10
+		overall["bias_after"] = math.Round(biasAfter/total*10) / 10
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      true,
+		"message":     "Fetched ETA report successfully!",
+		"overall":     overall,
+		"corrections": corrections,
+	})
+}
@@ -0,0 +1,110 @@
+package map_service
+
+import (
+	"WayPointPro/internal/app/services"
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/traffic"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// CreateFleetPlanHandler queues a multi-vehicle plan and returns its id for polling
+func CreateFleetPlanHandler(c *gin.Context) {
+	planner := services.NewFleetPlannerService(traffic.NewService())
+
+	var requestBody models.FleetPlanRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid JSON body"})
+		return
+	}
+
+	if err := validateFleetPlanRequest(requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": err.Error()})
+		return
+	}
+
+	plan, err := planner.SubmitPlan(requestBody)
+	if errors.Is(err, services.ErrPlannerBusy) {
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": false, "message": "Fleet planner is busy, try again later"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": false, "message": "Failed to queue fleet plan"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, plan)
+}
+
+// GetFleetPlanHandler returns the state, and once completed the result, of a fleet plan
+func GetFleetPlanHandler(c *gin.Context) {
+	planner := services.NewFleetPlannerService(traffic.NewService())
+
+	plan, err := planner.GetPlan(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": false, "message": "Fleet plan not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func validateFleetPlanRequest(request models.FleetPlanRequest) error {
+	if len(request.Vehicles) == 0 || len(request.Jobs) == 0 {
+		return fmt.Errorf("at least one vehicle and one job are required")
+	}
+
+	// Vehicle starts, ends and job locations share one matrix, count the distinct ones
+	locations := map[string]bool{}
+	ids := map[string]bool{}
+	for _, vehicle := range request.Vehicles {
+		if vehicle.ID == "" || vehicle.Start == "" {
+			return fmt.Errorf("every vehicle needs an 'id' and a 'start'")
+		}
+		if ids["vehicle:"+vehicle.ID] {
+			return fmt.Errorf("duplicate vehicle id %q", vehicle.ID)
+		}
+		ids["vehicle:"+vehicle.ID] = true
+		if vehicle.Capacity < 0 {
+			return fmt.Errorf("vehicle %q has a negative capacity", vehicle.ID)
+		}
+		if vehicle.ShiftStart.IsZero() {
+			return fmt.Errorf("vehicle %q needs a 'shift_start'", vehicle.ID)
+		}
+		if vehicle.ShiftEnd != nil && vehicle.ShiftEnd.Before(vehicle.ShiftStart) {
+			return fmt.Errorf("vehicle %q shift ends before it starts", vehicle.ID)
+		}
+		locations[strings.TrimSpace(vehicle.Start)] = true
+		if vehicle.End != "" {
+			locations[strings.TrimSpace(vehicle.End)] = true
+		}
+	}
+
+	for _, job := range request.Jobs {
+		if job.ID == "" || job.Location == "" {
+			return fmt.Errorf("every job needs an 'id' and a 'location'")
+		}
+		if ids["job:"+job.ID] {
+			return fmt.Errorf("duplicate job id %q", job.ID)
+		}
+		ids["job:"+job.ID] = true
+		if job.Demand < 0 || job.ServiceTime < 0 {
+			return fmt.Errorf("job %q has a negative demand or service time", job.ID)
+		}
+		if job.WindowStart != nil && job.WindowEnd != nil && job.WindowEnd.Before(*job.WindowStart) {
+			return fmt.Errorf("job %q window ends before it starts", job.ID)
+		}
+		locations[strings.TrimSpace(job.Location)] = true
+	}
+
+	if len(locations) > services.MaxFleetPlanLocations {
+		return fmt.Errorf("a fleet plan accepts at most %d distinct locations, got %d", services.MaxFleetPlanLocations, len(locations))
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package map_service
+
+import (
+	"WayPointPro/pkg/traffic"
+	"WayPointPro/pkg/valhalla"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Valhalla accepts at most 4 contours per isochrone request
+const maxIsochroneContours = 4
+
+// GetIsochroneHandler handles requests for reachability polygons around a location
+func GetIsochroneHandler(c *gin.Context) {
+
+	trafficService := traffic.NewService()
+	aggregator := newAggregator(trafficService)
+
+	// Parse JSON body, times are minutes and distances kilometers
+	var requestBody struct {
+		Location   string    `json:"location"`
+		Times      []float64 `json:"times"`
+		Distances  []float64 `json:"distances"`
+		Polygons   string    `json:"polygons"`
+		Denoise    *float64  `json:"denoise"`
+		Generalize *float64  `json:"generalize"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid JSON body"})
+		return
+	}
+
+	// Validate location and contours
+	if requestBody.Location == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Missing 'location' parameter"})
+		return
+	}
+	if (len(requestBody.Times) == 0) == (len(requestBody.Distances) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Provide either 'times' or 'distances'"})
+		return
+	}
+	if len(requestBody.Times) > maxIsochroneContours || len(requestBody.Distances) > maxIsochroneContours {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "At most 4 contours are allowed"})
+		return
+	}
+	if requestBody.Denoise != nil && (*requestBody.Denoise < 0 || *requestBody.Denoise > 1) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "'denoise' must be between 0 and 1"})
+		return
+	}
+	if requestBody.Generalize != nil && *requestBody.Generalize < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "'generalize' must not be negative"})
+		return
+	}
+
+	var contours []valhalla.Contour
+	for _, minutes := range requestBody.Times {
+		if minutes <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Contour values must be positive"})
+			return
+		}
+		contours = append(contours, valhalla.Contour{Time: minutes})
+	}
+	for _, kilometers := range requestBody.Distances {
+		if kilometers <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Contour values must be positive"})
+			return
+		}
+		contours = append(contours, valhalla.Contour{Distance: kilometers})
+	}
+
+	// Options for the isochrone
+	options := map[string]string{
+		"costing":  "auto",
+		"polygons": requestBody.Polygons,
+	}
+	if requestBody.Denoise != nil {
+		options["denoise"] = strconv.FormatFloat(*requestBody.Denoise, 'f', -1, 64)
+	}
+	if requestBody.Generalize != nil {
+		options["generalize"] = strconv.FormatFloat(*requestBody.Generalize, 'f', -1, 64)
+	}
+
+	// Generate a unique cached_key
+	cachedKey := trafficService.Cache.GenerateIsochroneCacheKey(requestBody.Location, contours, options)
+	log.Printf("cachedKey: %s", cachedKey)
+	// Check Redis cache
+	cachedData, err := trafficService.Cache.GetFromRedis(cachedKey)
+	if err == nil {
+		log.Printf("Retreived from cache redis")
+		var cachedIsochrone valhalla.IsochroneResponse
+		if err := json.Unmarshal(cachedData, &cachedIsochrone); err == nil {
+			c.JSON(http.StatusOK, cachedIsochrone)
+			return
+		}
+	}
+
+	isochrone, err := aggregator.GetAggregatedIsochrone(requestBody.Location, contours, options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": false, "message": "Failed to fetch isochrone"})
+		return
+	}
+
+	trafficService.Cache.CacheIsochroneResponse(cachedKey, isochrone)
+	c.JSON(http.StatusOK, isochrone)
+}
@@ -0,0 +1,99 @@
+package map_service
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/traffic"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of GPS points accepted in one trace
+const maxTracePoints = 5000
+
+// GetMatchHandler handles requests for snapping a GPS trace to the road network
+func GetMatchHandler(c *gin.Context) {
+
+	trafficService := traffic.NewService()
+	aggregator := newAggregator(trafficService)
+
+	// Parse JSON body, timestamps are unix seconds and radiuses meters
+	var requestBody struct {
+		Coordinates   string    `json:"coordinates"`
+		Timestamps    []int64   `json:"timestamps"`
+		Radiuses      []float64 `json:"radiuses"`
+		MaxGapSeconds float64   `json:"max_gap_seconds"`
+		MaxGapMeters  float64   `json:"max_gap_meters"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid JSON body"})
+		return
+	}
+
+	// Validate coordinates and timestamps
+	if requestBody.Coordinates == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Missing 'coordinates' parameter"})
+		return
+	}
+	coordinates := strings.Split(requestBody.Coordinates, ";")
+	if len(coordinates) < 2 || len(coordinates) > maxTracePoints {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Between 2 and 5000 points are required"})
+		return
+	}
+	if len(requestBody.Timestamps) != len(coordinates) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "'timestamps' must have one entry per coordinate"})
+		return
+	}
+	if len(requestBody.Radiuses) != 0 && len(requestBody.Radiuses) != len(coordinates) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "'radiuses' must have one entry per coordinate"})
+		return
+	}
+
+	points := make([]models.TracePoint, len(coordinates))
+	for i, coordinate := range coordinates {
+		parts := strings.Split(coordinate, ",")
+		if len(parts) != 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid coordinate format: " + coordinate})
+			return
+		}
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if lonErr != nil || latErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid coordinate format: " + coordinate})
+			return
+		}
+		if i > 0 && requestBody.Timestamps[i] < requestBody.Timestamps[i-1] {
+			c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "'timestamps' must not decrease"})
+			return
+		}
+		points[i] = models.TracePoint{Lon: lon, Lat: lat, Timestamp: requestBody.Timestamps[i]}
+		if len(requestBody.Radiuses) != 0 {
+			points[i].Radius = requestBody.Radiuses[i]
+		}
+	}
+
+	// Default gap thresholds if not provided
+	maxGapSeconds := 120.0
+	if requestBody.MaxGapSeconds > 0 {
+		maxGapSeconds = requestBody.MaxGapSeconds
+	}
+	maxGapMeters := 2000.0
+	if requestBody.MaxGapMeters > 0 {
+		maxGapMeters = requestBody.MaxGapMeters
+	}
+
+	// Options for the match
+	options := map[string]string{
+		"costing": "auto",
+	}
+
+	segments := aggregator.MatchTrace(points, maxGapSeconds, maxGapMeters, options)
+	response := models.TransformMatch(points, segments)
+	if !response.Status {
+		c.JSON(http.StatusUnprocessableEntity, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,81 @@
+package map_service
+
+import (
+	"WayPointPro/internal/models"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Maximum number of cells (sources x destinations) per matrix request
+const maxMatrixCells = 2500
+
+// GetMatrixHandler handles requests for many-to-many distance/duration matrices
+func GetMatrixHandler(c *gin.Context) {
+
+	trafficService := newTrafficService()
+	aggregator := newAggregator(trafficService)
+
+	// Parse JSON body
+	var requestBody struct {
+		Sources      string `json:"sources"`
+		Destinations string `json:"destinations"`
+		// Forces a registered engine such as osrm or valhalla, the healthy engine is picked when empty
+		Engine string `json:"engine"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid JSON body"})
+		return
+	}
+
+	// Validate coordinates
+	if requestBody.Sources == "" || requestBody.Destinations == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Missing 'sources' or 'destinations' parameter"})
+		return
+	}
+	cells := len(strings.Split(requestBody.Sources, ";")) * len(strings.Split(requestBody.Destinations, ";"))
+	if cells > maxMatrixCells {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Too many sources/destinations for a single matrix request"})
+		return
+	}
+
+	if requestBody.Engine != "" {
+		if _, err := aggregator.Engines.Get(requestBody.Engine); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": err.Error()})
+			return
+		}
+	}
+
+	// Options for the matrix
+	options := map[string]string{
+		"costing": "auto",
+		"engine":  requestBody.Engine,
+	}
+
+	// Generate a unique cached_key
+	cachedKey := trafficService.Cache.GenerateMatrixCacheKey(requestBody.Sources, requestBody.Destinations, options)
+	log.Printf("cachedKey: %s", cachedKey)
+	// Check Redis cache
+	cachedData, err := trafficService.Cache.GetFromRedis(cachedKey)
+	if err == nil {
+		log.Printf("Retreived from cache redis")
+		var cachedMatrix models.TransformedMatrix
+		err := json.Unmarshal(cachedData, &cachedMatrix)
+		if err == nil {
+			c.JSON(http.StatusOK, cachedMatrix)
+			return
+		}
+	}
+
+	table, err := aggregator.GetAggregatedMatrix(requestBody.Sources, requestBody.Destinations, options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": false, "message": "Failed to fetch matrix"})
+		return
+	}
+
+	response := models.TransformMatrix(table)
+	trafficService.Cache.CacheMatrixResponse(cachedKey, response)
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,96 @@
+package map_service
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/routing"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveMatrix(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	useTrafficService(t)
+	return serve(t, "/api/matrix", GetMatrixHandler, body)
+}
+
+func TestGetMatrixHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		primaryErr error
+		body       string
+		engine     string
+	}{
+		{
+			name:   "primary engine answers",
+			body:   `{"sources": "46.6753,24.7136;46.7,24.75", "destinations": "46.68,24.72"}`,
+			engine: routing.EngineOSRM,
+		},
+		{
+			name:       "fails over when the primary engine is down",
+			primaryErr: &osrm.ServerError{StatusCode: http.StatusBadGateway},
+			body:       `{"sources": "46.6753,24.7136;46.7,24.75", "destinations": "46.68,24.72"}`,
+			engine:     routing.EngineValhalla,
+		},
+		{
+			name:   "forced engine skips the primary",
+			body:   `{"sources": "46.6753,24.7136;46.7,24.75", "destinations": "46.68,24.72", "engine": "valhalla"}`,
+			engine: routing.EngineValhalla,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := routing.NewFakeEngine(routing.EngineOSRM)
+			primary.Err = tt.primaryErr
+			useEngines(t, primary, routing.NewFakeEngine(routing.EngineValhalla))
+
+			recorder := serveMatrix(t, tt.body)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+			}
+			var response models.TransformedMatrix
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if !response.Status || response.Engine != tt.engine {
+				t.Errorf("status %v from engine %q, want true from %q", response.Status, response.Engine, tt.engine)
+			}
+			if len(response.Rows) != 2 || len(response.Rows[0]) != 1 || response.FailedCells != 0 {
+				t.Fatalf("rows = %+v, want 2x1 routed cells", response.Rows)
+			}
+			if cell := response.Rows[0][0]; cell.Status != "ok" || cell.Distance.Value <= 0 || cell.Duration.Value <= 0 {
+				t.Errorf("cell = %+v, want a routed distance and duration", cell)
+			}
+		})
+	}
+}
+
+func TestGetMatrixHandlerErrors(t *testing.T) {
+	down := routing.NewFakeEngine(routing.EngineOSRM)
+	down.Err = &osrm.ServerError{StatusCode: http.StatusBadGateway}
+	useEngines(t, down, routing.NewFakeEngine(routing.EngineValhalla))
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"missing destinations", `{"sources": "46.6753,24.7136"}`, http.StatusBadRequest},
+		{"too many cells", `{"sources": "` + strings.Repeat("46.7,24.7;", maxMatrixCells) + `46.7,24.7", "destinations": "46.68,24.72"}`, http.StatusBadRequest},
+		{"unknown engine", `{"sources": "46.6753,24.7136", "destinations": "46.68,24.72", "engine": "graphhopper"}`, http.StatusBadRequest},
+		{"forced engine down", `{"sources": "46.6753,24.7136", "destinations": "46.68,24.72", "engine": "osrm"}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serveMatrix(t, tt.body)
+			if recorder.Code != tt.code {
+				t.Errorf("status = %d, want %d, body %s", recorder.Code, tt.code, recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package map_service
+
+import (
+	"WayPointPro/internal/models"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// Maximum number of points snapped in one request
+const maxNearestPoints = 100
+
+// GetNearestHandler handles requests for snapping points to the closest road
+func GetNearestHandler(c *gin.Context) {
+
+	trafficService := newTrafficService()
+	aggregator := newAggregator(trafficService)
+
+	// Parse JSON body
+	var requestBody struct {
+		Coordinates string `json:"coordinates"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid JSON body"})
+		return
+	}
+
+	// Validate coordinates
+	if requestBody.Coordinates == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Missing 'coordinates' parameter"})
+		return
+	}
+	if len(strings.Split(requestBody.Coordinates, ";")) > maxNearestPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "At most 100 points are allowed"})
+		return
+	}
+
+	// Options for the lookup
+	options := map[string]string{
+		"costing": "auto",
+	}
+
+	waypoints, err := aggregator.GetAggregatedNearest(requestBody.Coordinates, options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": false, "message": "Failed to snap points"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TransformNearest(waypoints))
+}
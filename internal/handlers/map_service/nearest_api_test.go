@@ -0,0 +1,132 @@
+package map_service
+
+import (
+	"WayPointPro/internal/app/services"
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/routing"
+	"WayPointPro/pkg/traffic"
+	"WayPointPro/pkg/valhalla"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// useEngines runs the handlers on the given engines for the rest of the test
+func useEngines(t *testing.T, engines ...routing.RoutingEngine) {
+	t.Helper()
+	monitor := services.NewEngineMonitor(routing.NewRegistryOf(engines...))
+	previous := engineMonitor
+	engineMonitor = func() *services.EngineMonitor { return monitor }
+	t.Cleanup(func() { engineMonitor = previous })
+}
+
+// useTrafficService gives the handlers a traffic service whose Redis never answers, every lookup is a cache miss
+func useTrafficService(t *testing.T) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve a port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: address, MaxRetries: -1})
+	t.Cleanup(func() { redisClient.Close() })
+	service := &traffic.Service{
+		HTTPClient: http.DefaultClient,
+		Cache:      &traffic.Cache{RedisClient: redisClient, CTX: context.Background()},
+	}
+	previous := newTrafficService
+	newTrafficService = func() *traffic.Service { return service }
+	t.Cleanup(func() { newTrafficService = previous })
+}
+
+// serve posts a JSON body to a handler
+func serve(t *testing.T, path string, handler gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(path, handler)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func serveNearest(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	useTrafficService(t)
+	return serve(t, "/api/nearest", GetNearestHandler, body)
+}
+
+func TestGetNearestHandler(t *testing.T) {
+	useEngines(t, routing.NewFakeEngine(routing.EngineOSRM))
+
+	recorder := serveNearest(t, `{"coordinates": "46.6753,24.7136;46.7,24.75"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+
+	var response models.TransformedNearest
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !response.Status || len(response.Waypoints) != 2 {
+		t.Fatalf("response = %+v, want two snapped points", response)
+	}
+	if location := response.Waypoints[1].Location; location[0] != 46.7 || location[1] != 24.75 {
+		t.Errorf("second waypoint at %v, want [46.7 24.75]", location)
+	}
+}
+
+func TestGetNearestHandlerFailsOver(t *testing.T) {
+	primary := routing.NewFakeEngine(routing.EngineValhalla)
+	primary.Err = &valhalla.ServerError{StatusCode: http.StatusServiceUnavailable, Message: "valhalla is down"}
+	useEngines(t, primary, routing.NewFakeEngine(routing.EngineOSRM))
+
+	recorder := serveNearest(t, `{"coordinates": "46.6753,24.7136"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestGetNearestHandlerErrors(t *testing.T) {
+	down := routing.NewFakeEngine(routing.EngineOSRM)
+	down.Err = &osrm.ServerError{StatusCode: http.StatusBadGateway}
+	useEngines(t, down)
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"missing coordinates", `{}`, http.StatusBadRequest},
+		{"too many points", `{"coordinates": "` + strings.Repeat("46.7,24.7;", maxNearestPoints) + `46.7,24.7"}`, http.StatusBadRequest},
+		{"every engine failing", `{"coordinates": "46.6753,24.7136"}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serveNearest(t, tt.body)
+			if recorder.Code != tt.code {
+				t.Errorf("status = %d, want %d, body %s", recorder.Code, tt.code, recorder.Body)
+			}
+			var response struct {
+				Status bool `json:"status"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Status {
+				t.Errorf("body = %s, want status false", recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package map_service
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/traffic"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// Maximum number of stops accepted by the trip optimizer
+const maxOptimizeStops = 50
+
+// GetOptimizedTripHandler handles requests for ordering unordered stops into the best trip
+func GetOptimizedTripHandler(c *gin.Context) {
+
+	trafficService := traffic.NewService()
+	aggregator := newAggregator(trafficService)
+
+	// Parse JSON body
+	var requestBody struct {
+		Stops     string `json:"stops"`
+		Start     string `json:"start"`
+		End       string `json:"end"`
+		Roundtrip string `json:"roundtrip"`
+		Legs      string `json:"legs"`
+		Traffic   string `json:"traffic"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid JSON body"})
+		return
+	}
+
+	// Validate stops
+	if requestBody.Stops == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Missing 'stops' parameter"})
+		return
+	}
+	stops := strings.Split(requestBody.Stops, ";")
+	if len(stops) < 2 || len(stops) > maxOptimizeStops {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Between 2 and 50 stops are required"})
+		return
+	}
+	roundtrip := requestBody.Roundtrip == "true"
+	if roundtrip && requestBody.End != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "'end' cannot be combined with 'roundtrip'"})
+		return
+	}
+
+	//Default 'legs' if not provided
+	legs := "false"
+	if requestBody.Legs != "" {
+		legs = requestBody.Legs
+	}
+
+	// Options for the route
+	options := map[string]string{
+		"overview":   "full",
+		"geometries": "geojson",
+		"steps":      legs,
+		"traffic":    requestBody.Traffic,
+		"costing":    "auto",
+	}
+
+	route, order, err := aggregator.GetOptimizedTrip(stops, requestBody.Start, requestBody.End, roundtrip, options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": false, "message": "Failed to optimize trip"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TransformTrip(route, order))
+}
@@ -0,0 +1,41 @@
+package map_service
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/traffic"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ExportRouteHandler renders a stored route as GeoJSON, GPX or KML, the id is the one returned by POST /api/route
+func ExportRouteHandler(c *gin.Context) {
+	trafficService := traffic.NewService()
+
+	id := c.Param("id")
+	format := c.DefaultQuery("format", models.FormatGeoJSON)
+	if !models.IsExportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid 'format', expected geojson, gpx or kml"})
+		return
+	}
+
+	cachedData, err := trafficService.Cache.GetFromRedis(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": false, "message": "Route not found or expired"})
+		return
+	}
+	var route models.TransformedRoute
+	if err := json.Unmarshal(cachedData, &route); err != nil || route.ID != id {
+		c.JSON(http.StatusNotFound, gin.H{"status": false, "message": "Route not found or expired"})
+		return
+	}
+
+	body, contentType, err := models.RenderRoute(route, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": false, "message": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"route-%.8s.%s\"", id, format))
+	c.Data(http.StatusOK, contentType, body)
+}
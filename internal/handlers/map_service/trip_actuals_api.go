@@ -0,0 +1,148 @@
+package map_service
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/eta"
+	"WayPointPro/pkg/traffic"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"time"
+)
+
+// CreateTripActualHandler records the actual duration and distance of a completed trip next to its quoted ETA,
+// the quote is the one stored when route_id was served or the quoted_duration, quoted_distance and origin of the body
+func CreateTripActualHandler(c *gin.Context) {
+	var requestBody struct {
+		RouteID        string    `json:"route_id"`
+		QuotedDuration float64   `json:"quoted_duration"`
+		QuotedDistance float64   `json:"quoted_distance"`
+		Origin         []float64 `json:"origin"`
+		// RFC 3339 times of the trip, distance in km
+		StartedAt string  `json:"started_at"`
+		EndedAt   string  `json:"ended_at"`
+		Distance  float64 `json:"distance"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid JSON body"})
+		return
+	}
+
+	startedAt, err := time.Parse(time.RFC3339, requestBody.StartedAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid 'started_at', expected RFC 3339"})
+		return
+	}
+	endedAt, err := time.Parse(time.RFC3339, requestBody.EndedAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Invalid 'ended_at', expected RFC 3339"})
+		return
+	}
+	actualDuration := endedAt.Sub(startedAt).Seconds()
+	if actualDuration <= 0 || actualDuration > 24*3600 {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "'ended_at' must be after 'started_at' and within 24 hours"})
+		return
+	}
+	if requestBody.Distance < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "'distance' cannot be negative"})
+		return
+	}
+
+	actual := eta.TripActual{
+		RouteID:        requestBody.RouteID,
+		QuotedDuration: requestBody.QuotedDuration,
+		QuotedDistance: requestBody.QuotedDistance,
+		ActualDuration: actualDuration,
+		ActualDistance: requestBody.Distance,
+		StartedAt:      startedAt,
+		EndedAt:        endedAt,
+	}
+	calibrator := eta.SharedCalibrator()
+	correction := 0.0
+
+	if requestBody.RouteID != "" {
+		quote, err := loadRouteQuote(requestBody.RouteID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"status": false, "message": "Route not found, send quoted_duration and origin instead"})
+			return
+		}
+		actual.QuotedDuration = quote.Duration
+		actual.EngineDuration = quote.EngineDuration
+		actual.QuotedDistance = quote.Distance
+		actual.Region = quote.Region
+		correction = quote.Correction
+	} else {
+		if len(requestBody.Origin) != 2 || requestBody.QuotedDuration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"status": false, "message": "Send 'route_id' or 'quoted_duration' with an [lon, lat] 'origin'"})
+			return
+		}
+		actual.Region = calibrator.RegionOf(requestBody.Origin)
+	}
+	if actual.Region == "" {
+		actual.Region = eta.DefaultRegion
+	}
+	if correction <= 0 {
+		correction = calibrator.CorrectionOf(actual.Region, startedAt)
+	}
+	actual.CalibratedDuration = actual.QuotedDuration / correction
+	if actual.EngineDuration <= 0 {
+		// Quotes sent in the body, or served before the engine duration was stored, are uncalibrated here
+		actual.EngineDuration = calibrator.Uncalibrate(actual.Region, startedAt, actual.CalibratedDuration)
+	}
+
+	if err := eta.SaveActual(actual); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": false, "message": "Failed to save trip actual"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":          true,
+		"message":         "Trip actual recorded successfully!",
+		"region":          actual.Region,
+		"quoted_duration": actual.QuotedDuration,
+		"actual_duration": actualDuration,
+		"error":           math.Round((actual.QuotedDuration-actualDuration)*10) / 10,
+	})
+}
+
+// routeQuote returns the ETA a route is served with, the traffic duration when known
+func routeQuote(route models.TransformedRoute) eta.Quote {
+	quote := eta.Quote{
+		RouteID:  route.ID,
+		Duration: route.TrafficDuration.Value,
+		Distance: route.Distance.Value,
+		QuotedAt: time.Now(),
+	}
+	if quote.Duration <= 0 {
+		quote.Duration = route.Duration.Value
+	}
+	if route.Calibration != nil {
+		quote.Region = route.Calibration.Region
+		quote.Correction = route.Calibration.Correction
+		quote.EngineDuration = route.Calibration.EngineDuration
+	} else if len(route.Waypoints) > 0 {
+		quote.Region = eta.SharedCalibrator().RegionOf(route.Waypoints[0].Location)
+	}
+	return quote
+}
+
+// loadRouteQuote returns the stored quote of a route, routes served before quotes were stored
+// are still read from the route cache
+func loadRouteQuote(routeID string) (*eta.Quote, error) {
+	quote, err := eta.LoadQuote(routeID)
+	if err == nil {
+		return quote, nil
+	}
+
+	cachedData, cacheErr := traffic.NewService().Cache.GetFromRedis(routeID)
+	if cacheErr != nil {
+		return nil, err
+	}
+	var route models.TransformedRoute
+	if json.Unmarshal(cachedData, &route) != nil || route.ID != routeID {
+		return nil, err
+	}
+	cached := routeQuote(route)
+	return &cached, nil
+}
@@ -2,9 +2,7 @@ package models
 
 import (
 	"WayPointPro/pkg/osrm"
-	"fmt"
 	"math"
-	"math/rand"
 )
 
 type DirectionsValueObject struct {
@@ -13,35 +11,94 @@ type DirectionsValueObject struct {
 }
 
 type TransformedRoute struct {
-	Status          bool                  `json:"status"`
-	Message         string                `json:"message"`
+	Status          bool                     `json:"status"`
+	Message         string                   `json:"message"`
+	ID              string                   `json:"id,omitempty"`
+	Labels          []string                 `json:"labels,omitempty"`
+	Distance        DirectionsValueObject    `json:"distance"`
+	TrafficDuration DirectionsValueObject    `json:"traffic_duration"`
+	Duration        DirectionsValueObject    `json:"duration"`
+	Geometry        interface{}              `json:"geometry"`
+	GeometryFormat  string                   `json:"geometry_format,omitempty"`
+	Legs            []osrm.Leg               `json:"legs"`
+	Waypoints       []osrm.Waypoint          `json:"waypoints"`
+	Alternatives    []TransformedAlternative `json:"alternatives,omitempty"`
+	Preferences     *osrm.Preferences        `json:"preferences,omitempty"`
+	Traffic         *osrm.TrafficSnapshot    `json:"traffic,omitempty"`
+	Calibration     *osrm.ETACalibration     `json:"calibration,omitempty"`
+	Engine          string                   `json:"engine,omitempty"`
+}
+
+// TransformedAlternative is an alternative to the primary route
+type TransformedAlternative struct {
+	Labels          []string              `json:"labels"`
 	Distance        DirectionsValueObject `json:"distance"`
 	TrafficDuration DirectionsValueObject `json:"traffic_duration"`
 	Duration        DirectionsValueObject `json:"duration"`
 	Geometry        interface{}           `json:"geometry"`
 	Legs            []osrm.Leg            `json:"legs"`
-	Waypoints       []osrm.Waypoint       `json:"waypoints"`
 }
 
-// TransformRoute transforms the OSRM route data into the desired format
-func TransformRoute(route *osrm.RouteResponse) TransformedRoute {
-	// Create DurationObject
-	randomNumber := float64(rand.Intn(61) + 120) // 61 = (180 - 120 + 1)
-	duration := route.Routes[0].Duration + randomNumber
-	durationObject := DirectionsValueObject{
+// Route labels
+const (
+	LabelFastest     = "fastest"
+	LabelShortest    = "shortest"
+	LabelFewestTurns = "fewest_turns"
+)
+
+// TransformRoute transforms the OSRM route data into the desired format, texts are written in lang
+func TransformRoute(route *osrm.RouteResponse, lang string) TransformedRoute {
+	labels := labelRoutes(route.Routes)
+
+	var alternatives []TransformedAlternative
+	for i, alternative := range route.Routes[1:] {
+		alternatives = append(alternatives, TransformedAlternative{
+			Labels:          labels[i+1],
+			Distance:        transformDistance(alternative.Distance, lang),
+			TrafficDuration: transformTrafficDuration(alternative.TrafficDuration, lang),
+			Duration:        transformDuration(alternative.Duration, lang),
+			Geometry:        alternative.Geometry.Coordinates,
+			Legs:            alternative.Legs,
+		})
+	}
+
+	// Transform and return the route
+	return TransformedRoute{
+		Status:          true,
+		Message:         "Fetched route successfully!",
+		Labels:          labels[0],
+		Distance:        transformDistance(route.Routes[0].Distance, lang),
+		TrafficDuration: transformTrafficDuration(route.Routes[0].TrafficDuration, lang),
+		Duration:        transformDuration(route.Routes[0].Duration, lang),
+		Geometry:        route.Routes[0].Geometry.Coordinates,
+		Legs:            route.Routes[0].Legs,
+		Waypoints:       route.Waypoints,
+		Alternatives:    alternatives,
+		Preferences:     route.Preferences,
+		Traffic:         route.Traffic,
+		Calibration:     route.Calibration,
+		Engine:          route.Engine,
+	}
+}
+
+// Create DurationObject
+func transformDuration(duration float64, lang string) DirectionsValueObject {
+	return DirectionsValueObject{
 		Value: duration,
-		Text:  fmt.Sprintf("%.1f mins", math.Round(duration/60*10)/10),
+		Text:  minutesText(duration, lang),
 	}
+}
 
-	// Create TrafficDuration
-	trafficDuration := route.Routes[0].TrafficDuration
-	trafficDurationObject := DirectionsValueObject{
+// Create TrafficDuration
+func transformTrafficDuration(trafficDuration float64, lang string) DirectionsValueObject {
+	return DirectionsValueObject{
 		Value: math.Round(trafficDuration*10) / 10,
-		Text:  fmt.Sprintf("%.1f mins", math.Round(trafficDuration/60*10)/10),
+		Text:  minutesText(trafficDuration, lang),
 	}
+}
 
-	// Create Distance
-	distance := route.Routes[0].Distance
+// Create Distance
+func transformDistance(distance float64, lang string) DirectionsValueObject {
 	//if distance > 1500 {
 	//	distance = distance / 1000 * 10
 	//} else {
@@ -49,20 +106,87 @@ func TransformRoute(route *osrm.RouteResponse) TransformedRoute {
 	//}
 	distance = distance * 10
 
-	distanceObject := DirectionsValueObject{
+	return DirectionsValueObject{
 		Value: distance / 10,
-		Text:  fmt.Sprintf("%.1f km", math.Round(distance)/10),
+		Text:  kilometersText(distance/10, lang),
 	}
+}
 
-	// Transform and return the route
-	return TransformedRoute{
-		Status:          true,
-		Message:         "Fetched route successfully!",
-		Distance:        distanceObject,
-		TrafficDuration: trafficDurationObject,
-		Duration:        durationObject,
-		Geometry:        route.Routes[0].Geometry.Coordinates,
-		Legs:            route.Routes[0].Legs,
-		Waypoints:       route.Waypoints,
+// labelRoutes marks the fastest, shortest and fewest-turns route, ties go to the earlier route
+func labelRoutes(routes []osrm.Route) [][]string {
+	labels := make([][]string, len(routes))
+	for i := range labels {
+		labels[i] = []string{}
+	}
+
+	fastest, shortest, fewestTurns := 0, 0, -1
+	for i, route := range routes {
+		if route.Duration < routes[fastest].Duration {
+			fastest = i
+		}
+		if route.Distance < routes[shortest].Distance {
+			shortest = i
+		}
+		// Turns are only known when steps were requested
+		if turns := countTurns(route); turns >= 0 && (fewestTurns < 0 || turns < countTurns(routes[fewestTurns])) {
+			fewestTurns = i
+		}
+	}
+
+	labels[fastest] = append(labels[fastest], LabelFastest)
+	labels[shortest] = append(labels[shortest], LabelShortest)
+	if fewestTurns >= 0 {
+		labels[fewestTurns] = append(labels[fewestTurns], LabelFewestTurns)
+	}
+	return labels
+}
+
+// Maneuver types that keep the direction of travel, an exit roundabout closes the roundabout already counted
+var straightManeuvers = map[string]bool{
+	"depart":          true,
+	"arrive":          true,
+	"continue":        true,
+	"new name":        true,
+	"notification":    true,
+	"use lane":        true,
+	"exit roundabout": true,
+	"exit rotary":     true,
+}
+
+// countTurns counts the maneuvers of a route that change direction, -1 when unknown
+func countTurns(route osrm.Route) int {
+	turns, known := 0, false
+	for _, leg := range route.Legs {
+		for _, step := range leg.Steps {
+			known = true
+			if isTurn(step.Maneuver.Type, step.Maneuver.Modifier) {
+				turns++
+			}
+		}
+	}
+	if !known {
+		return -1
+	}
+	return turns
+}
+
+// isTurn reports whether a maneuver changes direction
+func isTurn(maneuverType, modifier string) bool {
+	return !straightManeuvers[maneuverType] && modifier != "straight"
+}
+
+type TransformedTrip struct {
+	TransformedRoute
+	Order []int `json:"order"`
+}
+
+// TransformTrip transforms an optimized trip into the desired format, order maps the visit sequence to input stop indexes
+func TransformTrip(route *osrm.RouteResponse, order []int) TransformedTrip {
+	transformedRoute := TransformRoute(route, "")
+	transformedRoute.Message = "Optimized trip successfully!"
+
+	return TransformedTrip{
+		TransformedRoute: transformedRoute,
+		Order:            order,
 	}
 }
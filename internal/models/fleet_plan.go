@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// FleetPlanRequest is the body of a fleet planning request, locations are "lon,lat" strings
+type FleetPlanRequest struct {
+	Vehicles []FleetVehicle `json:"vehicles"`
+	Jobs     []FleetJob     `json:"jobs"`
+}
+
+type FleetVehicle struct {
+	ID         string     `json:"id"`
+	Start      string     `json:"start"`
+	End        string     `json:"end,omitempty"`
+	Capacity   int        `json:"capacity"`
+	ShiftStart time.Time  `json:"shift_start"`
+	ShiftEnd   *time.Time `json:"shift_end,omitempty"`
+}
+
+type FleetJob struct {
+	ID          string     `json:"id"`
+	Location    string     `json:"location"`
+	Demand      int        `json:"demand"`
+	ServiceTime float64    `json:"service_time"`
+	WindowStart *time.Time `json:"window_start,omitempty"`
+	WindowEnd   *time.Time `json:"window_end,omitempty"`
+}
+
+// FleetPlanResult is the stored state of an asynchronous fleet plan
+type FleetPlanResult struct {
+	ID          string                `json:"id"`
+	Status      string                `json:"status"`
+	Message     string                `json:"message"`
+	CreatedAt   time.Time             `json:"created_at"`
+	CompletedAt *time.Time            `json:"completed_at,omitempty"`
+	Routes      []FleetRoute          `json:"routes"`
+	Unassigned  []FleetUnassigned     `json:"unassigned"`
+	Distance    DirectionsValueObject `json:"distance"`
+	Duration    DirectionsValueObject `json:"duration"`
+}
+
+type FleetRoute struct {
+	VehicleID string                `json:"vehicle_id"`
+	Load      int                   `json:"load"`
+	Start     time.Time             `json:"start"`
+	End       time.Time             `json:"end"`
+	Distance  DirectionsValueObject `json:"distance"`
+	Duration  DirectionsValueObject `json:"duration"`
+	Stops     []FleetStop           `json:"stops"`
+}
+
+type FleetStop struct {
+	JobID     string    `json:"job_id"`
+	Location  string    `json:"location"`
+	Arrival   time.Time `json:"arrival"`
+	Departure time.Time `json:"departure"`
+	Wait      float64   `json:"wait"`
+	Load      int       `json:"load"`
+}
+
+type FleetUnassigned struct {
+	JobID  string `json:"job_id"`
+	Reason string `json:"reason"`
+}
+
+// Fleet plan statuses
+const (
+	FleetPlanPending   = "pending"
+	FleetPlanRunning   = "running"
+	FleetPlanCompleted = "completed"
+	FleetPlanFailed    = "failed"
+)
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Unit words of the value texts per language, [minutes, kilometers]
+var unitTexts = map[string][2]string{
+	"en": {"mins", "km"},
+	"ar": {"دقيقة", "كم"},
+}
+
+// textLanguage returns the supported text language of a tag such as "ar-SA", English otherwise
+func textLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if len(lang) >= 2 {
+		if _, ok := unitTexts[lang[:2]]; ok {
+			return lang[:2]
+		}
+	}
+	return "en"
+}
+
+// minutesText formats a duration in seconds as minutes with one decimal
+func minutesText(duration float64, lang string) string {
+	return fmt.Sprintf("%.1f %s", math.Round(duration/60*10)/10, unitTexts[textLanguage(lang)][0])
+}
+
+// kilometersText formats a distance in kilometers with one decimal
+func kilometersText(distance float64, lang string) string {
+	return fmt.Sprintf("%.1f %s", math.Round(distance*10)/10, unitTexts[textLanguage(lang)][1])
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"WayPointPro/pkg/osrm"
+	"fmt"
+	"math"
+)
+
+// DefaultTraceRadius is the assumed GPS accuracy in meters when a point has no radius
+const DefaultTraceRadius = 10.0
+
+// TracePoint is one timestamped GPS sample of a trace
+type TracePoint struct {
+	Lon       float64
+	Lat       float64
+	Timestamp int64
+	Radius    float64
+}
+
+// MatchSegment is a continuous piece of trace, Response is nil when the engine could not match it
+type MatchSegment struct {
+	Indexes  []int
+	Response *osrm.MatchResponse
+}
+
+type MatchedRoute struct {
+	Distance     DirectionsValueObject `json:"distance"`
+	Duration     DirectionsValueObject `json:"duration"`
+	Confidence   float64               `json:"confidence"`
+	Geometry     [][]float64           `json:"geometry"`
+	PointIndexes []int                 `json:"point_indexes"`
+}
+
+type MatchedPoint struct {
+	Index      int       `json:"index"`
+	Location   []float64 `json:"location"`
+	Snapped    []float64 `json:"snapped"`
+	Matched    bool      `json:"matched"`
+	Confidence float64   `json:"confidence"`
+	Distance   float64   `json:"distance"`
+	Name       string    `json:"name,omitempty"`
+}
+
+type TransformedMatch struct {
+	Status        bool                  `json:"status"`
+	Message       string                `json:"message"`
+	Distance      DirectionsValueObject `json:"distance"`
+	Duration      DirectionsValueObject `json:"duration"`
+	Matchings     []MatchedRoute        `json:"matchings"`
+	Points        []MatchedPoint        `json:"points"`
+	DroppedPoints []int                 `json:"dropped_points"`
+}
+
+// TransformMatch merges the matched segments of a trace into the desired format
+func TransformMatch(points []TracePoint, segments []MatchSegment) TransformedMatch {
+	result := TransformedMatch{
+		Status:        true,
+		Message:       "Matched trace successfully!",
+		Matchings:     []MatchedRoute{},
+		Points:        make([]MatchedPoint, len(points)),
+		DroppedPoints: []int{},
+	}
+	for i, point := range points {
+		result.Points[i] = MatchedPoint{Index: i, Location: []float64{point.Lon, point.Lat}}
+	}
+
+	var distance, duration float64
+	for _, segment := range segments {
+		if segment.Response == nil {
+			continue
+		}
+
+		// Matchings of this segment start after the ones already collected
+		offset := len(result.Matchings)
+		for _, matching := range segment.Response.Matchings {
+			distance += matching.Distance
+			duration += matching.Duration
+			result.Matchings = append(result.Matchings, MatchedRoute{
+				Distance:     distanceObject(matching.Distance),
+				Duration:     durationObject(matching.Duration),
+				Confidence:   matching.Confidence,
+				Geometry:     matching.Geometry.Coordinates,
+				PointIndexes: []int{},
+			})
+		}
+
+		for k, tracepoint := range segment.Response.Tracepoints {
+			if tracepoint == nil || k >= len(segment.Indexes) {
+				continue
+			}
+			index := segment.Indexes[k]
+			if result.Points[index].Matched {
+				// Boundary point shared by two chunks, keep the first match
+				continue
+			}
+			matchingIndex := offset + tracepoint.MatchingsIndex
+			if matchingIndex >= len(result.Matchings) {
+				continue
+			}
+
+			radius := points[index].Radius
+			if radius == 0 {
+				radius = DefaultTraceRadius
+			}
+			// Confidence of the matching weighted by how far the point had to move (gaussian GPS noise)
+			emission := math.Exp(-0.5 * math.Pow(tracepoint.Distance/radius, 2))
+
+			result.Points[index].Matched = true
+			result.Points[index].Snapped = tracepoint.Location
+			result.Points[index].Distance = math.Round(tracepoint.Distance*10) / 10
+			result.Points[index].Name = tracepoint.Name
+			result.Points[index].Confidence = math.Round(result.Matchings[matchingIndex].Confidence*emission*1000) / 1000
+			result.Matchings[matchingIndex].PointIndexes = append(result.Matchings[matchingIndex].PointIndexes, index)
+		}
+	}
+
+	for i, point := range result.Points {
+		if !point.Matched {
+			result.DroppedPoints = append(result.DroppedPoints, i)
+		}
+	}
+
+	result.Distance = distanceObject(distance)
+	result.Duration = durationObject(duration)
+	if len(result.Matchings) == 0 {
+		result.Status = false
+		result.Message = "Trace could not be matched"
+	}
+
+	return result
+}
+
+func distanceObject(distance float64) DirectionsValueObject {
+	return DirectionsValueObject{
+		Value: math.Round(distance*1000) / 1000,
+		Text:  fmt.Sprintf("%.1f km", math.Round(distance*10)/10),
+	}
+}
+
+func durationObject(duration float64) DirectionsValueObject {
+	return DirectionsValueObject{
+		Value: math.Round(duration*10) / 10,
+		Text:  fmt.Sprintf("%.1f mins", math.Round(duration/60*10)/10),
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"WayPointPro/pkg/osrm"
+	"fmt"
+	"math"
+)
+
+type MatrixCell struct {
+	Status   string                 `json:"status"`
+	Distance *DirectionsValueObject `json:"distance"`
+	Duration *DirectionsValueObject `json:"duration"`
+}
+
+type TransformedMatrix struct {
+	Status       bool            `json:"status"`
+	Message      string          `json:"message"`
+	Sources      []osrm.Waypoint `json:"sources"`
+	Destinations []osrm.Waypoint `json:"destinations"`
+	Rows         [][]MatrixCell  `json:"rows"`
+	FailedCells  int             `json:"failed_cells"`
+	Engine       string          `json:"engine,omitempty"`
+}
+
+// TransformMatrix transforms the OSRM table data into the desired format
+func TransformMatrix(table *osrm.TableResponse) TransformedMatrix {
+	rows := make([][]MatrixCell, len(table.Durations))
+	failedCells := 0
+
+	for i, durations := range table.Durations {
+		rows[i] = make([]MatrixCell, len(durations))
+		for j, duration := range durations {
+			var distance *float64
+			if i < len(table.Distances) && j < len(table.Distances[i]) {
+				distance = table.Distances[i][j]
+			}
+
+			// A missing duration or distance means the engine could not route this pair
+			if duration == nil || distance == nil {
+				rows[i][j] = MatrixCell{Status: "not_found"}
+				failedCells++
+				continue
+			}
+
+			rows[i][j] = MatrixCell{
+				Status: "ok",
+				Distance: &DirectionsValueObject{
+					Value: *distance,
+					Text:  fmt.Sprintf("%.1f km", math.Round(*distance*10)/10),
+				},
+				Duration: &DirectionsValueObject{
+					Value: math.Round(*duration*10) / 10,
+					Text:  fmt.Sprintf("%.1f mins", math.Round(*duration/60*10)/10),
+				},
+			}
+		}
+	}
+
+	return TransformedMatrix{
+		Status:       true,
+		Message:      "Fetched matrix successfully!",
+		Sources:      table.Sources,
+		Destinations: table.Destinations,
+		Rows:         rows,
+		FailedCells:  failedCells,
+		Engine:       table.Engine,
+	}
+}
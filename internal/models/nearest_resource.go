@@ -0,0 +1,28 @@
+package models
+
+import "WayPointPro/pkg/osrm"
+
+type TransformedNearest struct {
+	Status    bool             `json:"status"`
+	Message   string           `json:"message"`
+	Waypoints []*osrm.Waypoint `json:"waypoints"`
+}
+
+// TransformNearest transforms snapped waypoints into the desired format, unsnapped points stay null
+func TransformNearest(waypoints []*osrm.Waypoint) TransformedNearest {
+	for _, waypoint := range waypoints {
+		if waypoint != nil {
+			return TransformedNearest{
+				Status:    true,
+				Message:   "Snapped points successfully!",
+				Waypoints: waypoints,
+			}
+		}
+	}
+
+	return TransformedNearest{
+		Status:    false,
+		Message:   "No point could be snapped to a road",
+		Waypoints: waypoints,
+	}
+}
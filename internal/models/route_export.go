@@ -0,0 +1,361 @@
+package models
+
+import (
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/polyline"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Route export formats
+const (
+	FormatJSON    = "json"
+	FormatGeoJSON = "geojson"
+	FormatGPX     = "gpx"
+	FormatKML     = "kml"
+)
+
+// Content types of the export formats
+var exportContentTypes = map[string]string{
+	FormatGeoJSON: "application/geo+json",
+	FormatGPX:     "application/gpx+xml",
+	FormatKML:     "application/vnd.google-earth.kml+xml",
+}
+
+// IsExportFormat reports whether a route can be rendered in the format
+func IsExportFormat(format string) bool {
+	_, ok := exportContentTypes[format]
+	return ok
+}
+
+// RenderRoute renders the primary route as a GeoJSON FeatureCollection, GPX or KML document,
+// every leg and waypoint is its own feature with distance and duration. It returns the content type
+func RenderRoute(route TransformedRoute, format string) ([]byte, string, error) {
+	legs, waypoints := exportFeatures(route)
+
+	var body []byte
+	var err error
+	switch format {
+	case FormatGeoJSON:
+		body, err = renderGeoJSON(route, legs, waypoints)
+	case FormatGPX:
+		body, err = renderGPX(route, legs, waypoints)
+	case FormatKML:
+		body, err = renderKML(route, legs, waypoints)
+	default:
+		return nil, "", fmt.Errorf("unsupported format %q, expected geojson, gpx or kml", format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return body, exportContentTypes[format], nil
+}
+
+// exportFeature is a leg line or a waypoint point, distance and duration are counted from the start for waypoints
+type exportFeature struct {
+	Name        string
+	Coordinates [][]float64
+	Distance    float64
+	Duration    float64
+}
+
+func exportFeatures(route TransformedRoute) ([]exportFeature, []exportFeature) {
+	var legs, waypoints []exportFeature
+	var distance, duration float64
+
+	for i, waypoint := range route.Waypoints {
+		name := waypoint.Name
+		if name == "" || name == "break" {
+			name = fmt.Sprintf("Waypoint %d", i+1)
+		}
+		if len(waypoint.Location) >= 2 {
+			waypoints = append(waypoints, exportFeature{
+				Name:        name,
+				Coordinates: [][]float64{waypoint.Location},
+				Distance:    math.Round(distance*1000) / 1000,
+				Duration:    math.Round(duration*10) / 10,
+			})
+		}
+		if i < len(route.Legs) {
+			distance += route.Legs[i].Distance
+			duration += route.Legs[i].Duration
+		}
+	}
+
+	// Legs without their own geometry get their piece of the route line between the waypoints
+	routeLine := routeCoordinates(route.Geometry, route.GeometryFormat)
+	shapePrecision := polyline.Precision6
+	if precision, ok := geometryPrecisions[route.GeometryFormat]; ok {
+		shapePrecision = precision
+	}
+	start := 0
+	for i, leg := range route.Legs {
+		coordinates := legCoordinates(leg, shapePrecision)
+		if len(coordinates) == 0 && i+1 < len(route.Waypoints) {
+			end := closestPoint(routeLine, route.Waypoints[i+1].Location, start)
+			if i == len(route.Legs)-1 {
+				end = len(routeLine) - 1
+			}
+			if end >= start && end < len(routeLine) {
+				coordinates = routeLine[start : end+1]
+			}
+			start = end
+			// A simplified line may have lost the waypoints, fall back to a straight segment
+			if len(coordinates) < 2 && len(route.Waypoints[i].Location) >= 2 {
+				coordinates = [][]float64{route.Waypoints[i].Location, route.Waypoints[i+1].Location}
+			}
+		}
+		legs = append(legs, exportFeature{
+			Name:        fmt.Sprintf("Leg %d", i+1),
+			Coordinates: coordinates,
+			Distance:    math.Round(leg.Distance*1000) / 1000,
+			Duration:    math.Round(leg.Duration*10) / 10,
+		})
+	}
+	// Without legs the whole route geometry is the only line
+	if len(legs) == 0 {
+		legs = append(legs, exportFeature{
+			Name:        "Route",
+			Coordinates: routeLine,
+			Distance:    route.Distance.Value,
+			Duration:    route.Duration.Value,
+		})
+	}
+	return legs, waypoints
+}
+
+// legCoordinates returns the leg line from its geometry, its steps or its shape encoded at the precision
+func legCoordinates(leg osrm.Leg, precision int) [][]float64 {
+	if leg.Geometry != nil && len(leg.Geometry.Coordinates) > 0 {
+		return leg.Geometry.Coordinates
+	}
+	var coordinates [][]float64
+	for _, step := range leg.Steps {
+		if step.Geometry != nil {
+			coordinates = append(coordinates, step.Geometry.Coordinates...)
+		}
+	}
+	if len(coordinates) == 0 && leg.Shape != "" {
+		coordinates = polyline.Decode(leg.Shape, precision)
+	}
+	return coordinates
+}
+
+// closestPoint returns the index of the line point closest to a location, searching from an index on
+func closestPoint(line [][]float64, location []float64, from int) int {
+	best, bestDistance := from, math.Inf(1)
+	if len(location) < 2 {
+		return best
+	}
+	for i := from; i < len(line); i++ {
+		distance := math.Pow(line[i][0]-location[0], 2) + math.Pow(line[i][1]-location[1], 2)
+		if distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+// routeCoordinates reads the route geometry whether it was built in memory, decoded from the cache
+// or encoded as a polyline
+func routeCoordinates(geometry interface{}, format string) [][]float64 {
+	switch value := geometry.(type) {
+	case string:
+		if precision, ok := geometryPrecisions[format]; ok {
+			return polyline.Decode(value, precision)
+		}
+	case [][]float64:
+		return value
+	case []interface{}:
+		var coordinates [][]float64
+		for _, point := range value {
+			pair, ok := point.([]interface{})
+			if !ok || len(pair) < 2 {
+				continue
+			}
+			lon, _ := pair[0].(float64)
+			lat, _ := pair[1].(float64)
+			coordinates = append(coordinates, []float64{lon, lat})
+		}
+		return coordinates
+	}
+	return nil
+}
+
+func renderGeoJSON(route TransformedRoute, legs, waypoints []exportFeature) ([]byte, error) {
+	features := []map[string]interface{}{}
+	for i, leg := range legs {
+		features = append(features, map[string]interface{}{
+			"type":     "Feature",
+			"geometry": map[string]interface{}{"type": "LineString", "coordinates": leg.Coordinates},
+			"properties": map[string]interface{}{
+				"kind":     "leg",
+				"index":    i,
+				"name":     leg.Name,
+				"distance": leg.Distance,
+				"duration": leg.Duration,
+			},
+		})
+	}
+	for i, waypoint := range waypoints {
+		features = append(features, map[string]interface{}{
+			"type":     "Feature",
+			"geometry": map[string]interface{}{"type": "Point", "coordinates": waypoint.Coordinates[0]},
+			"properties": map[string]interface{}{
+				"kind":     "waypoint",
+				"index":    i,
+				"name":     waypoint.Name,
+				"distance": waypoint.Distance,
+				"duration": waypoint.Duration,
+			},
+		})
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+		"properties": map[string]interface{}{
+			"distance":         route.Distance.Value,
+			"duration":         route.Duration.Value,
+			"traffic_duration": route.TrafficDuration.Value,
+		},
+	})
+}
+
+type gpxDocument struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Xmlns     string     `xml:"xmlns,attr"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Metadata  gpxMeta    `xml:"metadata"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Tracks    []gpxTrack `xml:"trk"`
+}
+
+type gpxMeta struct {
+	Name string `xml:"name"`
+	Desc string `xml:"desc"`
+}
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Name string  `xml:"name,omitempty"`
+	Desc string  `xml:"desc,omitempty"`
+}
+
+type gpxTrack struct {
+	Name    string     `xml:"name"`
+	Desc    string     `xml:"desc"`
+	Type    string     `xml:"type"`
+	Segment []gpxPoint `xml:"trkseg>trkpt"`
+}
+
+func renderGPX(route TransformedRoute, legs, waypoints []exportFeature) ([]byte, error) {
+	document := gpxDocument{
+		Xmlns:    "http://www.topografix.com/GPX/1/1",
+		Version:  "1.1",
+		Creator:  "WayPointPro",
+		Metadata: gpxMeta{Name: "Route", Desc: exportDescription(route.Distance.Value, route.Duration.Value)},
+	}
+	for _, waypoint := range waypoints {
+		document.Waypoints = append(document.Waypoints, gpxPoint{
+			Lat:  waypoint.Coordinates[0][1],
+			Lon:  waypoint.Coordinates[0][0],
+			Name: waypoint.Name,
+			Desc: exportDescription(waypoint.Distance, waypoint.Duration),
+		})
+	}
+	for _, leg := range legs {
+		track := gpxTrack{Name: leg.Name, Desc: exportDescription(leg.Distance, leg.Duration), Type: "leg"}
+		for _, point := range leg.Coordinates {
+			track.Segment = append(track.Segment, gpxPoint{Lat: point[1], Lon: point[0]})
+		}
+		document.Tracks = append(document.Tracks, track)
+	}
+
+	return marshalXML(document)
+}
+
+type kmlDocument struct {
+	XMLName  xml.Name `xml:"kml"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Document struct {
+		Name       string         `xml:"name"`
+		Data       []kmlData      `xml:"ExtendedData>Data"`
+		Placemarks []kmlPlacemark `xml:"Placemark"`
+	} `xml:"Document"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPlacemark struct {
+	Name       string    `xml:"name"`
+	Data       []kmlData `xml:"ExtendedData>Data"`
+	LineString *kmlShape `xml:"LineString,omitempty"`
+	Point      *kmlShape `xml:"Point,omitempty"`
+}
+
+type kmlShape struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+func renderKML(route TransformedRoute, legs, waypoints []exportFeature) ([]byte, error) {
+	var document kmlDocument
+	document.Xmlns = "http://www.opengis.net/kml/2.2"
+	document.Document.Name = "Route"
+	document.Document.Data = kmlMeasures("route", route.Distance.Value, route.Duration.Value)
+
+	for _, leg := range legs {
+		document.Document.Placemarks = append(document.Document.Placemarks, kmlPlacemark{
+			Name:       leg.Name,
+			Data:       kmlMeasures("leg", leg.Distance, leg.Duration),
+			LineString: &kmlShape{Coordinates: kmlCoordinates(leg.Coordinates)},
+		})
+	}
+	for _, waypoint := range waypoints {
+		document.Document.Placemarks = append(document.Document.Placemarks, kmlPlacemark{
+			Name:  waypoint.Name,
+			Data:  kmlMeasures("waypoint", waypoint.Distance, waypoint.Duration),
+			Point: &kmlShape{Coordinates: kmlCoordinates(waypoint.Coordinates)},
+		})
+	}
+
+	return marshalXML(document)
+}
+
+func kmlMeasures(kind string, distance, duration float64) []kmlData {
+	return []kmlData{
+		{Name: "kind", Value: kind},
+		{Name: "distance", Value: strconv.FormatFloat(distance, 'f', -1, 64)},
+		{Name: "duration", Value: strconv.FormatFloat(duration, 'f', -1, 64)},
+	}
+}
+
+// kmlCoordinates writes "lon,lat lon,lat" tuples
+func kmlCoordinates(coordinates [][]float64) string {
+	tuples := make([]string, 0, len(coordinates))
+	for _, point := range coordinates {
+		tuples = append(tuples, fmt.Sprintf("%f,%f", point[0], point[1]))
+	}
+	return strings.Join(tuples, " ")
+}
+
+func exportDescription(distance, duration float64) string {
+	return fmt.Sprintf("distance=%s duration=%s", strconv.FormatFloat(distance, 'f', -1, 64), strconv.FormatFloat(duration, 'f', -1, 64))
+}
+
+func marshalXML(document interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
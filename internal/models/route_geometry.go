@@ -0,0 +1,59 @@
+package models
+
+import (
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/polyline"
+)
+
+// Route geometry formats
+const (
+	GeometryGeoJSON   = "geojson"
+	GeometryPolyline  = "polyline"
+	GeometryPolyline6 = "polyline6"
+)
+
+// Polyline precision of the encoded geometry formats
+var geometryPrecisions = map[string]int{
+	GeometryPolyline:  polyline.Precision5,
+	GeometryPolyline6: polyline.Precision6,
+}
+
+// IsGeometryFormat reports whether the route geometry can be written in the format
+func IsGeometryFormat(format string) bool {
+	_, ok := geometryPrecisions[format]
+	return ok || format == GeometryGeoJSON
+}
+
+// EncodeGeometry writes the route, alternative, leg and step lines as encoded polylines,
+// leg and step lines move to their shape. GeoJSON keeps the coordinate arrays
+func (r *TransformedRoute) EncodeGeometry(format string) {
+	precision, ok := geometryPrecisions[format]
+	if !ok {
+		return
+	}
+
+	r.GeometryFormat = format
+	r.Geometry = polyline.Encode(routeCoordinates(r.Geometry, GeometryGeoJSON), precision)
+	for i := range r.Alternatives {
+		r.Alternatives[i].Geometry = polyline.Encode(routeCoordinates(r.Alternatives[i].Geometry, GeometryGeoJSON), precision)
+		encodeLegs(r.Alternatives[i].Legs, precision)
+	}
+	encodeLegs(r.Legs, precision)
+}
+
+func encodeLegs(legs []osrm.Leg, precision int) {
+	for i := range legs {
+		coordinates := legCoordinates(legs[i], polyline.Precision6)
+		for j := range legs[i].Steps {
+			if step := &legs[i].Steps[j]; step.Geometry != nil {
+				step.Shape = polyline.Encode(step.Geometry.Coordinates, precision)
+				step.Geometry = nil
+			}
+		}
+		if len(coordinates) == 0 {
+			continue
+		}
+		legs[i].Shape = polyline.Encode(coordinates, precision)
+		legs[i].Geometry = nil
+	}
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/polyline"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeGeometryEncodesSteps(t *testing.T) {
+	first := [][]float64{{46.675, 24.713}, {46.68, 24.72}}
+	second := [][]float64{{46.68, 24.72}, {46.69, 24.73}}
+	line := [][]float64{first[0], first[1], second[1]}
+
+	route := TransformedRoute{
+		Geometry: line,
+		Legs: []osrm.Leg{{
+			Geometry: &osrm.Geometry{Coordinates: line, Type: "LineString"},
+			Steps: []osrm.Step{
+				{Geometry: &osrm.Geometry{Coordinates: first, Type: "LineString"}},
+				{Geometry: &osrm.Geometry{Coordinates: second, Type: "LineString"}},
+			},
+		}},
+	}
+	route.EncodeGeometry(GeometryPolyline6)
+
+	leg := route.Legs[0]
+	if leg.Geometry != nil || leg.Shape != polyline.Encode(line, polyline.Precision6) {
+		t.Errorf("leg geometry %v, shape %q, want only the encoded line", leg.Geometry, leg.Shape)
+	}
+	for i, want := range [][][]float64{first, second} {
+		step := leg.Steps[i]
+		if step.Geometry != nil {
+			t.Errorf("step %d keeps its coordinates next to the shape", i)
+		}
+		if got := polyline.Decode(step.Shape, polyline.Precision6); !reflect.DeepEqual(got, want) {
+			t.Errorf("step %d shape decodes to %v, want %v", i, got, want)
+		}
+	}
+}
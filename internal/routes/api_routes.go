@@ -12,6 +12,18 @@ func registerAPIRoutes(router *gin.Engine) {
 	apiRouter := router.Group("/api")
 	{
 		apiRouter.POST("/route", map_service.GetRouteHandler)                       // POST /api/route
+		apiRouter.GET("/route/:id/export", map_service.ExportRouteHandler)          // GET /api/route/:id/export
+		apiRouter.POST("/matrix", map_service.GetMatrixHandler)                     // POST /api/matrix
+		apiRouter.POST("/optimize", map_service.GetOptimizedTripHandler)            // POST /api/optimize
+		apiRouter.POST("/fleet/plan", map_service.CreateFleetPlanHandler)           // POST /api/fleet/plan
+		apiRouter.GET("/fleet/plan/:id", map_service.GetFleetPlanHandler)           // GET /api/fleet/plan/:id
+		apiRouter.POST("/isochrone", map_service.GetIsochroneHandler)               // POST /api/isochrone
+		apiRouter.POST("/match", map_service.GetMatchHandler)                       // POST /api/match
+		apiRouter.POST("/nearest", map_service.GetNearestHandler)                   // POST /api/nearest
+		apiRouter.GET("/admin/engines", map_service.GetEnginesHandler)              // GET /api/admin/engines
+		apiRouter.GET("/eta/coefficients", map_service.GetETACoefficientsHandler)   // GET /api/eta/coefficients
+		apiRouter.GET("/eta/report", map_service.GetETAReportHandler)               // GET /api/eta/report
+		apiRouter.POST("/trips/actuals", map_service.CreateTripActualHandler)       // POST /api/trips/actuals
 		apiRouter.GET("/gecode", map_service.GetGeCodingHandler)                    // GET /api/gecode
 		apiRouter.GET("/place_details", map_service.GetPlaceDetailsHandler)         // GET /api/place_details
 		apiRouter.GET("/create_access_token", map_service.CreateAccessTokenHandler) // GET /api/gecode
@@ -0,0 +1,190 @@
+package eta
+
+import (
+	"WayPointPro/internal/db"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Corrections are only fitted for buckets with at least this many trips
+const minCorrectionSamples = 20
+
+// Every heldOutEvery-th trip of a bucket, by start time, is left out of the fit to measure the errors on
+const heldOutEvery = 5
+
+// Fitted factors are clamped to this range so a few broken trips cannot wreck the ETAs
+const (
+	minCorrectionFactor = 0.5
+	maxCorrectionFactor = 2.0
+)
+
+// TripActual is a completed trip next to the ETA it was quoted with, durations in seconds and distances in km.
+// CalibratedDuration is the quote without the correction factor, corrections are fitted against it.
+// EngineDuration is the quote before any calibration, the baseline the errors are reported against
+type TripActual struct {
+	RouteID            string
+	Region             string
+	QuotedDuration     float64
+	CalibratedDuration float64
+	EngineDuration     float64
+	QuotedDistance     float64
+	ActualDuration     float64
+	ActualDistance     float64
+	StartedAt          time.Time
+	EndedAt            time.Time
+}
+
+// Correction is the factor fitted for the trips of a region starting in an hour. The errors are measured on
+// held-out trips, before with the engine ETA and after with the calibrated ETA times the factor.
+// Bias is the mean of ETA minus actual, positive when late
+type Correction struct {
+	Region     string    `json:"region"`
+	Hour       int       `json:"hour"`
+	Factor     float64   `json:"factor"`
+	Samples    int       `json:"samples"`
+	HeldOut    int       `json:"held_out"`
+	MAEBefore  float64   `json:"mae_before"`
+	BiasBefore float64   `json:"bias_before"`
+	MAEAfter   float64   `json:"mae_after"`
+	BiasAfter  float64   `json:"bias_after"`
+	FittedAt   time.Time `json:"fitted_at"`
+}
+
+// SaveActual stores a completed trip in trip_actuals
+func SaveActual(actual TripActual) error {
+	pool := db.Connect()
+	if pool == nil {
+		return fmt.Errorf("database unavailable")
+	}
+
+	query := `
+		INSERT INTO trip_actuals (route_id, region, hour, quoted_duration, calibrated_duration, engine_duration,
+			quoted_distance, actual_duration, actual_distance, started_at, ended_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+	`
+	_, err := pool.Exec(context.Background(), query,
+		actual.RouteID, actual.Region, actual.StartedAt.In(time.Local).Hour(), actual.QuotedDuration,
+		actual.CalibratedDuration, actual.EngineDuration, actual.QuotedDistance, actual.ActualDuration,
+		actual.ActualDistance, actual.StartedAt, actual.EndedAt)
+	return err
+}
+
+// FitCorrections fits a factor per region and hour by least squares through the origin,
+// factor = sum(calibrated * actual) / sum(calibrated^2), so that calibrated * factor best matches the actuals.
+// The fit leaves every heldOutEvery-th trip out, the errors are measured on those trips
+func FitCorrections(actuals []TripActual) []Correction {
+	type bucket struct {
+		region string
+		hour   int
+	}
+	grouped := map[bucket][]TripActual{}
+	var order []bucket
+	for _, actual := range actuals {
+		key := bucket{actual.Region, actual.StartedAt.In(time.Local).Hour()}
+		if _, ok := grouped[key]; !ok {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], actual)
+	}
+
+	var corrections []Correction
+	fittedAt := time.Now()
+	for _, key := range order {
+		trips := grouped[key]
+		if len(trips) < minCorrectionSamples {
+			continue
+		}
+		training, heldOut := splitTrips(trips)
+
+		var product, square float64
+		for _, trip := range training {
+			product += trip.CalibratedDuration * trip.ActualDuration
+			square += trip.CalibratedDuration * trip.CalibratedDuration
+		}
+		if square == 0 {
+			continue
+		}
+		factor := math.Min(math.Max(product/square, minCorrectionFactor), maxCorrectionFactor)
+
+		maeBefore, biasBefore := etaError(heldOut, func(trip TripActual) float64 { return trip.EngineDuration })
+		maeAfter, biasAfter := etaError(heldOut, func(trip TripActual) float64 { return trip.CalibratedDuration * factor })
+		corrections = append(corrections, Correction{
+			Region:     key.region,
+			Hour:       key.hour,
+			Factor:     math.Round(factor*10000) / 10000,
+			Samples:    len(training),
+			HeldOut:    len(heldOut),
+			MAEBefore:  maeBefore,
+			BiasBefore: biasBefore,
+			MAEAfter:   maeAfter,
+			BiasAfter:  biasAfter,
+			FittedAt:   fittedAt,
+		})
+	}
+	return corrections
+}
+
+// splitTrips orders the trips by start time and holds every heldOutEvery-th one out of the fit
+func splitTrips(trips []TripActual) ([]TripActual, []TripActual) {
+	ordered := append([]TripActual{}, trips...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].StartedAt.Before(ordered[j].StartedAt) })
+
+	var training, heldOut []TripActual
+	for i, trip := range ordered {
+		if i%heldOutEvery == heldOutEvery-1 {
+			heldOut = append(heldOut, trip)
+		} else {
+			training = append(training, trip)
+		}
+	}
+	return training, heldOut
+}
+
+// etaError returns the mean absolute error and the mean error in seconds of an ETA of the trips
+func etaError(trips []TripActual, eta func(trip TripActual) float64) (float64, float64) {
+	var absolute, signed float64
+	for _, trip := range trips {
+		difference := eta(trip) - trip.ActualDuration
+		absolute += math.Abs(difference)
+		signed += difference
+	}
+	count := float64(len(trips))
+	return math.Round(absolute/count*10) / 10, math.Round(signed/count*10) / 10
+}
+
+// Report returns the fitted corrections with their error before and after, ordered by region and hour
+func Report() ([]Correction, error) {
+	pool := db.Connect()
+	if pool == nil {
+		return nil, fmt.Errorf("database unavailable")
+	}
+	return loadCorrections(context.Background(), pool)
+}
+
+func loadCorrections(ctx context.Context, pool *pgxpool.Pool) ([]Correction, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT region, hour, factor, samples, held_out, mae_before, bias_before, mae_after, bias_after, fitted_at
+		FROM eta_corrections
+		ORDER BY region, hour`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var corrections []Correction
+	for rows.Next() {
+		var correction Correction
+		if err := rows.Scan(&correction.Region, &correction.Hour, &correction.Factor, &correction.Samples, &correction.HeldOut,
+			&correction.MAEBefore, &correction.BiasBefore, &correction.MAEAfter, &correction.BiasAfter,
+			&correction.FittedAt); err != nil {
+			return nil, err
+		}
+		corrections = append(corrections, correction)
+	}
+	return corrections, rows.Err()
+}
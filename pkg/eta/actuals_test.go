@@ -0,0 +1,79 @@
+package eta
+
+import (
+	"testing"
+	"time"
+)
+
+// trips returns n trips of a region starting at 08:00 local time, a minute apart
+func trips(region string, n int, trip func(i int) TripActual) []TripActual {
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local)
+	var actuals []TripActual
+	for i := 0; i < n; i++ {
+		actual := trip(i)
+		actual.Region = region
+		actual.StartedAt = start.Add(time.Duration(i) * time.Minute)
+		actuals = append(actuals, actual)
+	}
+	return actuals
+}
+
+func TestFitCorrections(t *testing.T) {
+	// The calibrated ETA is 20% short on the fitted trips, the engine ETA was 200 s short of the calibrated one
+	actuals := trips("riyadh", 25, func(i int) TripActual {
+		calibrated := float64(600 + 60*i)
+		return TripActual{CalibratedDuration: calibrated, EngineDuration: calibrated - 200, ActualDuration: calibrated * 1.2}
+	})
+	// Too few trips for a bucket of their own
+	actuals = append(actuals, trips("jeddah", minCorrectionSamples-1, func(i int) TripActual {
+		return TripActual{CalibratedDuration: 900, EngineDuration: 900, ActualDuration: 1800}
+	})...)
+
+	corrections := FitCorrections(actuals)
+	if len(corrections) != 1 {
+		t.Fatalf("corrections = %+v, want riyadh only", corrections)
+	}
+	correction := corrections[0]
+	if correction.Region != "riyadh" || correction.Hour != 8 || correction.Factor != 1.2 {
+		t.Errorf("fitted %s %d at %v, want riyadh 8 at 1.2", correction.Region, correction.Hour, correction.Factor)
+	}
+	if correction.Samples != 20 || correction.HeldOut != 5 {
+		t.Errorf("%d fitted and %d held-out trips, want 20 and 5", correction.Samples, correction.HeldOut)
+	}
+
+	// Held-out trips are 4, 9, 14, 19 and 24: calibrated 840, 1140, 1440, 1740 and 2040 s, mean 1440 s.
+	// The engine ETA is off by 0.2 * calibrated + 200 s, the corrected one is exact
+	if correction.MAEBefore != 488 || correction.BiasBefore != -488 {
+		t.Errorf("before: mae %v, bias %v, want 488 and -488", correction.MAEBefore, correction.BiasBefore)
+	}
+	if correction.MAEAfter != 0 || correction.BiasAfter != 0 {
+		t.Errorf("after: mae %v, bias %v, want 0 and 0", correction.MAEAfter, correction.BiasAfter)
+	}
+}
+
+func TestFitCorrectionsMeasuresHeldOutTrips(t *testing.T) {
+	// Held-out trips run twice as long as quoted, the fitted ones exactly as quoted
+	actuals := trips("riyadh", 20, func(i int) TripActual {
+		actual := TripActual{CalibratedDuration: 1000, EngineDuration: 1000, ActualDuration: 1000}
+		if i%heldOutEvery == heldOutEvery-1 {
+			actual.ActualDuration = 2000
+		}
+		return actual
+	})
+	// Reporting order must not change the split
+	for i, j := 0, len(actuals)-1; i < j; i, j = i+1, j-1 {
+		actuals[i], actuals[j] = actuals[j], actuals[i]
+	}
+
+	corrections := FitCorrections(actuals)
+	if len(corrections) != 1 {
+		t.Fatalf("corrections = %+v, want one", corrections)
+	}
+	correction := corrections[0]
+	if correction.Factor != 1 {
+		t.Errorf("factor = %v, want 1 from the fitted trips only", correction.Factor)
+	}
+	if correction.MAEAfter != 1000 || correction.BiasAfter != -1000 {
+		t.Errorf("after: mae %v, bias %v, want the held-out error 1000 and -1000", correction.MAEAfter, correction.BiasAfter)
+	}
+}
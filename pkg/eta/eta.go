@@ -0,0 +1,301 @@
+package eta
+
+import (
+	"WayPointPro/internal/db"
+	"WayPointPro/pkg/osrm"
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRegion holds the coefficients of trips outside every configured region
+const DefaultRegion = "default"
+
+// Coefficients are reloaded from Postgres after this long
+const refreshInterval = 5 * time.Minute
+
+// Region is a named bounding box coefficients apply to
+type Region struct {
+	Name  string  `json:"name"`
+	North float64 `json:"north"`
+	South float64 `json:"south"`
+	West  float64 `json:"west"`
+	East  float64 `json:"east"`
+}
+
+// Coefficient calibrates the durations of a region in a time bucket as duration * multiplier + offset.
+// An empty day of week matches every day and hour -1 every hour
+type Coefficient struct {
+	Region     string    `json:"region"`
+	DayOfWeek  string    `json:"day_of_week"`
+	Hour       int       `json:"hour"`
+	Multiplier float64   `json:"multiplier"`
+	Offset     float64   `json:"offset_seconds"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// identity leaves durations unchanged when no coefficient matches
+var identity = Coefficient{Region: DefaultRegion, Hour: -1, Multiplier: 1}
+
+// Calibrator applies the coefficients of the eta_regions and eta_calibration tables and the corrections
+// fitted from trip actuals to route durations
+type Calibrator struct {
+	mu           sync.Mutex
+	regions      []Region
+	coefficients []Coefficient
+	corrections  []Correction
+	loadedAt     time.Time
+}
+
+var (
+	calibratorInstance *Calibrator
+	calibratorOnce     sync.Once
+)
+
+// SharedCalibrator returns the process wide calibrator, coefficients are loaded on first use
+func SharedCalibrator() *Calibrator {
+	calibratorOnce.Do(func() {
+		calibratorInstance = &Calibrator{}
+	})
+	return calibratorInstance
+}
+
+// Snapshot returns the regions and coefficients in use and when they were loaded
+func (c *Calibrator) Snapshot() ([]Region, []Coefficient, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh()
+
+	regions := append([]Region{}, c.regions...)
+	coefficients := append([]Coefficient{}, c.coefficients...)
+	return regions, coefficients, c.loadedAt
+}
+
+// Apply calibrates the duration and traffic duration of every route, and the duration of its legs,
+// for a trip starting at departure, the region is the one containing the origin. The same route and departure always give the same durations
+func (c *Calibrator) Apply(route *osrm.RouteResponse, departure time.Time) {
+	if len(route.Routes) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	c.refresh()
+	region := c.regionOf(routeOrigin(route))
+	coefficient := c.lookup(region, departure)
+	correction := c.correction(region, departure)
+	c.mu.Unlock()
+
+	engineDuration := route.Routes[0].TrafficDuration
+	if engineDuration <= 0 {
+		engineDuration = route.Routes[0].Duration
+	}
+	for i := range route.Routes {
+		route.Routes[i].Duration = calibrate(route.Routes[i].Duration, coefficient, correction)
+		route.Routes[i].TrafficDuration = calibrate(route.Routes[i].TrafficDuration, coefficient, correction)
+		calibrateLegs(route.Routes[i].Legs, coefficient, correction)
+	}
+	route.Calibration = &osrm.ETACalibration{
+		Region:         region,
+		Bucket:         Bucket(departure),
+		Multiplier:     coefficient.Multiplier,
+		Offset:         coefficient.Offset,
+		Correction:     correction,
+		EngineDuration: engineDuration,
+	}
+}
+
+// RegionOf returns the name of the region containing a [lon, lat] point, the default region outside all of them
+func (c *Calibrator) RegionOf(point []float64) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh()
+	return c.regionOf(point)
+}
+
+// CorrectionOf returns the correction factor trips of a region starting at a time are quoted with
+func (c *Calibrator) CorrectionOf(region string, at time.Time) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh()
+	return c.correction(region, at)
+}
+
+// Uncalibrate returns the engine duration of a trip of a region starting at a time from its calibrated duration,
+// the duration must already be divided by its correction
+func (c *Calibrator) Uncalibrate(region string, at time.Time, duration float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh()
+	coefficient := c.lookup(region, at)
+	if duration <= 0 || coefficient.Multiplier <= 0 {
+		return duration
+	}
+	return math.Round((duration-coefficient.Offset)/coefficient.Multiplier*10) / 10
+}
+
+// Bucket formats the hourly bucket of a time as "Monday 08", in the server time zone like the traffic buckets
+func Bucket(at time.Time) string {
+	return at.In(time.Local).Format("Monday 15")
+}
+
+func calibrate(duration float64, coefficient Coefficient, correction float64) float64 {
+	if duration <= 0 {
+		return duration
+	}
+	return math.Round((duration*coefficient.Multiplier+coefficient.Offset)*correction*10) / 10
+}
+
+// calibrateLegs calibrates the leg durations, the offset applies once per trip so it is
+// shared between the legs by their duration
+func calibrateLegs(legs []osrm.Leg, coefficient Coefficient, correction float64) {
+	total := 0.0
+	for _, leg := range legs {
+		total += math.Max(leg.Duration, 0)
+	}
+	if total == 0 {
+		return
+	}
+	for j := range legs {
+		if legs[j].Duration <= 0 {
+			continue
+		}
+		share := coefficient
+		share.Offset = coefficient.Offset * legs[j].Duration / total
+		legs[j].Duration = calibrate(legs[j].Duration, share, correction)
+	}
+}
+
+// routeOrigin returns the [lon, lat] the trip starts at
+func routeOrigin(route *osrm.RouteResponse) []float64 {
+	if len(route.Waypoints) > 0 && len(route.Waypoints[0].Location) >= 2 {
+		return route.Waypoints[0].Location
+	}
+	if len(route.Routes[0].Geometry.Coordinates) > 0 {
+		return route.Routes[0].Geometry.Coordinates[0]
+	}
+	return nil
+}
+
+// regionOf returns the smallest region containing the point, regions are kept sorted by area.
+// The caller must hold the lock
+func (c *Calibrator) regionOf(point []float64) string {
+	if len(point) < 2 {
+		return DefaultRegion
+	}
+	for _, region := range c.regions {
+		if point[0] >= region.West && point[0] <= region.East && point[1] >= region.South && point[1] <= region.North {
+			return region.Name
+		}
+	}
+	return DefaultRegion
+}
+
+// lookup returns the most specific coefficient of the region and bucket: day and hour, hour, day, any time,
+// then the same for the default region. The caller must hold the lock
+func (c *Calibrator) lookup(region string, at time.Time) Coefficient {
+	at = at.In(time.Local)
+	day, hour := at.Weekday().String(), at.Hour()
+
+	regions := []string{region}
+	if region != DefaultRegion {
+		regions = append(regions, DefaultRegion)
+	}
+	for _, name := range regions {
+		for _, bucket := range []struct {
+			day  string
+			hour int
+		}{{day, hour}, {"", hour}, {day, -1}, {"", -1}} {
+			for _, coefficient := range c.coefficients {
+				if coefficient.Region == name && coefficient.DayOfWeek == bucket.day && coefficient.Hour == bucket.hour {
+					return coefficient
+				}
+			}
+		}
+	}
+	return identity
+}
+
+// correction returns the fitted factor of the region and hour, then of the default region, 1 without one.
+// The caller must hold the lock
+func (c *Calibrator) correction(region string, at time.Time) float64 {
+	hour := at.In(time.Local).Hour()
+	for _, name := range []string{region, DefaultRegion} {
+		for _, correction := range c.corrections {
+			if correction.Region == name && correction.Hour == hour {
+				return correction.Factor
+			}
+		}
+	}
+	return 1
+}
+
+// refresh reloads the tables when they are stale, the last coefficients stay in use when Postgres fails
+// and a failed corrections load only drops the corrections.
+// The caller must hold the lock
+func (c *Calibrator) refresh() {
+	if time.Since(c.loadedAt) < refreshInterval {
+		return
+	}
+	c.loadedAt = time.Now()
+
+	pool := db.Connect()
+	if pool == nil {
+		return
+	}
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT name, north, south, west, east FROM eta_regions`)
+	if err != nil {
+		log.Printf("Failed to load ETA regions: %v", err)
+		return
+	}
+	var regions []Region
+	for rows.Next() {
+		var region Region
+		if err := rows.Scan(&region.Name, &region.North, &region.South, &region.West, &region.East); err != nil {
+			rows.Close()
+			log.Printf("Failed to read ETA region: %v", err)
+			return
+		}
+		regions = append(regions, region)
+	}
+	rows.Close()
+
+	rows, err = pool.Query(ctx, `
+		SELECT region, day_of_week, hour, multiplier, offset_seconds, updated_at
+		FROM eta_calibration
+		ORDER BY region, day_of_week, hour`)
+	if err != nil {
+		log.Printf("Failed to load ETA coefficients: %v", err)
+		return
+	}
+	defer rows.Close()
+	var coefficients []Coefficient
+	for rows.Next() {
+		var coefficient Coefficient
+		if err := rows.Scan(&coefficient.Region, &coefficient.DayOfWeek, &coefficient.Hour, &coefficient.Multiplier, &coefficient.Offset, &coefficient.UpdatedAt); err != nil {
+			log.Printf("Failed to read ETA coefficient: %v", err)
+			return
+		}
+		coefficients = append(coefficients, coefficient)
+	}
+
+	// Nested regions such as a district inside a city resolve to the smaller one
+	sort.SliceStable(regions, func(i, j int) bool {
+		return (regions[i].North-regions[i].South)*(regions[i].East-regions[i].West) <
+			(regions[j].North-regions[j].South)*(regions[j].East-regions[j].West)
+	})
+	c.regions = regions
+	c.coefficients = coefficients
+
+	// Without corrections trips are quoted with factor 1, the coefficients still apply
+	corrections, err := loadCorrections(ctx, pool)
+	if err != nil {
+		log.Printf("Failed to load ETA corrections: %v", err)
+		corrections = nil
+	}
+	c.corrections = corrections
+}
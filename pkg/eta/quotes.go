@@ -0,0 +1,75 @@
+package eta
+
+import (
+	"WayPointPro/internal/db"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Quotes are kept this long, well past the trips they were served for and the fit window
+const QuoteRetention = 45 * 24 * time.Hour
+
+// ErrQuoteNotFound is returned by LoadQuote when no route was served with the id
+var ErrQuoteNotFound = errors.New("route quote not found")
+
+// Quote is the ETA a route was served with, duration in seconds and distance in km, EngineDuration is the
+// ETA before calibration. Unlike the cached route it outlives the Redis TTL so trips booked ahead can still be reported
+type Quote struct {
+	RouteID        string
+	Duration       float64
+	EngineDuration float64
+	Distance       float64
+	Region         string
+	Correction     float64
+	QuotedAt       time.Time
+}
+
+// SaveQuote stores the quote of a served route in route_quotes, a route served again replaces it
+func SaveQuote(quote Quote) error {
+	pool := db.Connect()
+	if pool == nil {
+		return fmt.Errorf("database unavailable")
+	}
+
+	query := `
+		INSERT INTO route_quotes (route_id, duration, engine_duration, distance, region, correction, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (route_id)
+		DO UPDATE SET
+			duration = EXCLUDED.duration,
+			engine_duration = EXCLUDED.engine_duration,
+			distance = EXCLUDED.distance,
+			region = EXCLUDED.region,
+			correction = EXCLUDED.correction,
+			created_at = EXCLUDED.created_at
+	`
+	_, err := pool.Exec(context.Background(), query,
+		quote.RouteID, quote.Duration, quote.EngineDuration, quote.Distance, quote.Region, quote.Correction, quote.QuotedAt)
+	return err
+}
+
+// LoadQuote returns the quote a route was served with
+func LoadQuote(routeID string) (*Quote, error) {
+	pool := db.Connect()
+	if pool == nil {
+		return nil, fmt.Errorf("database unavailable")
+	}
+
+	quote := Quote{RouteID: routeID}
+	err := pool.QueryRow(context.Background(), `
+		SELECT duration, engine_duration, distance, region, correction, created_at
+		FROM route_quotes
+		WHERE route_id = $1`, routeID).
+		Scan(&quote.Duration, &quote.EngineDuration, &quote.Distance, &quote.Region, &quote.Correction, &quote.QuotedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrQuoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
@@ -0,0 +1,156 @@
+package osrm
+
+import (
+	"strconv"
+	"strings"
+)
+
+// instructionTemplates hold the phrases of one language, a phrase is [without street name, with street name]
+// and may use the {modifier}, {side}, {direction}, {exit} and {name} placeholders
+type instructionTemplates struct {
+	modifiers  map[string]string
+	sides      map[string]string
+	directions [8]string
+	ordinals   []string
+	phrases    map[string][2]string
+}
+
+var instructionLanguages = map[string]instructionTemplates{
+	"en": {
+		modifiers: map[string]string{
+			"left":         "left",
+			"right":        "right",
+			"slight left":  "slightly left",
+			"slight right": "slightly right",
+			"sharp left":   "sharp left",
+			"sharp right":  "sharp right",
+			"straight":     "straight",
+			"uturn":        "around",
+		},
+		sides:      map[string]string{"left": "on the left", "right": "on the right"},
+		directions: [8]string{"north", "northeast", "east", "southeast", "south", "southwest", "west", "northwest"},
+		ordinals:   []string{"first", "second", "third", "fourth", "fifth", "sixth", "seventh", "eighth", "ninth", "tenth"},
+		phrases: map[string][2]string{
+			"depart":          {"Head {direction}", "Head {direction} on {name}"},
+			"turn":            {"Turn {modifier}", "Turn {modifier} onto {name}"},
+			"uturn":           {"Make a U-turn", "Make a U-turn onto {name}"},
+			"continue":        {"Continue {modifier}", "Continue onto {name}"},
+			"on ramp":         {"Take the ramp {side}", "Take the ramp {side} onto {name}"},
+			"off ramp":        {"Take the exit {side}", "Take the exit {side} onto {name}"},
+			"fork":            {"Keep {modifier} at the fork", "Keep {modifier} at the fork onto {name}"},
+			"merge":           {"Merge {modifier}", "Merge {modifier} onto {name}"},
+			"end of road":     {"Turn {modifier} at the end of the road", "Turn {modifier} onto {name}"},
+			"roundabout":      {"Enter the roundabout and take the {exit} exit", "Enter the roundabout and take the {exit} exit onto {name}"},
+			"exit roundabout": {"Exit the roundabout", "Exit the roundabout onto {name}"},
+			"ferry":           {"Take the ferry", "Take the ferry {name}"},
+			"arrive":          {"You have arrived at your destination", "You have arrived at your destination {side}"},
+		},
+	},
+	"ar": {
+		modifiers: map[string]string{
+			"left":         "يسارًا",
+			"right":        "يمينًا",
+			"slight left":  "قليلًا إلى اليسار",
+			"slight right": "قليلًا إلى اليمين",
+			"sharp left":   "بحدة إلى اليسار",
+			"sharp right":  "بحدة إلى اليمين",
+			"straight":     "مباشرة",
+			"uturn":        "للخلف",
+		},
+		sides:      map[string]string{"left": "على اليسار", "right": "على اليمين"},
+		directions: [8]string{"شمالًا", "نحو الشمال الشرقي", "شرقًا", "نحو الجنوب الشرقي", "جنوبًا", "نحو الجنوب الغربي", "غربًا", "نحو الشمال الغربي"},
+		ordinals:   []string{"الأول", "الثاني", "الثالث", "الرابع", "الخامس", "السادس", "السابع", "الثامن", "التاسع", "العاشر"},
+		phrases: map[string][2]string{
+			"depart":          {"اتجه {direction}", "اتجه {direction} على {name}"},
+			"turn":            {"انعطف {modifier}", "انعطف {modifier} إلى {name}"},
+			"uturn":           {"قم بالدوران للخلف", "قم بالدوران للخلف إلى {name}"},
+			"continue":        {"استمر {modifier}", "استمر على {name}"},
+			"on ramp":         {"اسلك المنحدر {side}", "اسلك المنحدر {side} إلى {name}"},
+			"off ramp":        {"اسلك المخرج {side}", "اسلك المخرج {side} إلى {name}"},
+			"fork":            {"التزم {modifier} عند التفرع", "التزم {modifier} عند التفرع إلى {name}"},
+			"merge":           {"اندمج {modifier}", "اندمج {modifier} إلى {name}"},
+			"end of road":     {"انعطف {modifier} في نهاية الطريق", "انعطف {modifier} إلى {name}"},
+			"roundabout":      {"ادخل الدوار واسلك المخرج {exit}", "ادخل الدوار واسلك المخرج {exit} إلى {name}"},
+			"exit roundabout": {"اخرج من الدوار", "اخرج من الدوار إلى {name}"},
+			"ferry":           {"استقل العبّارة", "استقل العبّارة {name}"},
+			"arrive":          {"لقد وصلت إلى وجهتك", "لقد وصلت إلى وجهتك {side}"},
+		},
+	},
+}
+
+// Maneuver types written with the phrase of another type
+var instructionAliases = map[string]string{
+	"new name":        "continue",
+	"notification":    "continue",
+	"use lane":        "continue",
+	"rotary":          "roundabout",
+	"roundabout turn": "turn",
+	"exit rotary":     "exit roundabout",
+}
+
+// InstructionLanguage returns the supported instruction language of a tag such as "ar-SA", English otherwise
+func InstructionLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if len(lang) >= 2 {
+		if _, ok := instructionLanguages[lang[:2]]; ok {
+			return lang[:2]
+		}
+	}
+	return "en"
+}
+
+// Instruction writes the text of a step from its maneuver type, modifier and street name
+func Instruction(step Step, lang string) string {
+	templates := instructionLanguages[InstructionLanguage(lang)]
+	maneuver := step.Maneuver
+
+	kind := maneuver.Type
+	if alias, ok := instructionAliases[kind]; ok {
+		kind = alias
+	}
+	if _, ok := templates.phrases[kind]; !ok {
+		kind = "continue"
+	}
+	switch {
+	case maneuver.Modifier == "ferry":
+		kind = "ferry"
+	case maneuver.Modifier == "uturn" && (kind == "turn" || kind == "continue"):
+		kind = "uturn"
+	}
+
+	// Arrival names the side of the street rather than the street
+	phrase := templates.phrases[kind]
+	text := phrase[0]
+	if (kind == "arrive" && templates.sides[maneuver.Modifier] != "") || (kind != "arrive" && step.Name != "") {
+		text = phrase[1]
+	}
+
+	exit := strconv.Itoa(maneuver.Exit)
+	if maneuver.Exit > 0 && maneuver.Exit <= len(templates.ordinals) {
+		exit = templates.ordinals[maneuver.Exit-1]
+	}
+
+	replacer := strings.NewReplacer(
+		"{modifier}", templates.modifiers[maneuver.Modifier],
+		"{side}", templates.sides[maneuver.Modifier],
+		"{direction}", templates.directions[((maneuver.BearingAfter+22)%360)/45],
+		"{exit}", exit,
+		"{name}", step.Name,
+	)
+	text = replacer.Replace(text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// LocalizeInstructions writes the instruction of every step that has none, replace also rewrites engine text
+func LocalizeInstructions(route *RouteResponse, lang string, replace bool) {
+	for i := range route.Routes {
+		for j := range route.Routes[i].Legs {
+			steps := route.Routes[i].Legs[j].Steps
+			for k := range steps {
+				if replace || steps[k].Instruction == "" {
+					steps[k].Instruction = Instruction(steps[k], lang)
+				}
+			}
+		}
+	}
+}
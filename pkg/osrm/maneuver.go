@@ -0,0 +1,112 @@
+package osrm
+
+import (
+	"WayPointPro/pkg/valhalla"
+	"strings"
+)
+
+// Valhalla maneuver types mapped to OSRM maneuver type and modifier
+var valhallaManeuvers = map[int][2]string{
+	0:  {"notification", ""},
+	1:  {"depart", ""},
+	2:  {"depart", "right"},
+	3:  {"depart", "left"},
+	4:  {"arrive", ""},
+	5:  {"arrive", "right"},
+	6:  {"arrive", "left"},
+	7:  {"new name", "straight"},
+	8:  {"continue", "straight"},
+	9:  {"turn", "slight right"},
+	10: {"turn", "right"},
+	11: {"turn", "sharp right"},
+	12: {"turn", "uturn"},
+	13: {"turn", "uturn"},
+	14: {"turn", "sharp left"},
+	15: {"turn", "left"},
+	16: {"turn", "slight left"},
+	17: {"on ramp", "straight"},
+	18: {"on ramp", "right"},
+	19: {"on ramp", "left"},
+	20: {"off ramp", "right"},
+	21: {"off ramp", "left"},
+	22: {"fork", "straight"},
+	23: {"fork", "slight right"},
+	24: {"fork", "slight left"},
+	25: {"merge", "straight"},
+	26: {"roundabout", ""},
+	27: {"exit roundabout", ""},
+	28: {"notification", "ferry"},
+	29: {"notification", "ferry"},
+	37: {"merge", "slight right"},
+	38: {"merge", "slight left"},
+}
+
+// Valhalla travel modes mapped to OSRM step modes
+var valhallaTravelModes = map[string]string{
+	"drive":      "driving",
+	"pedestrian": "walking",
+	"bicycle":    "cycling",
+	"transit":    "transit",
+}
+
+// maneuverKind returns the OSRM maneuver type and modifier of a Valhalla maneuver type
+func maneuverKind(valhallaType int) (string, string) {
+	kind, ok := valhallaManeuvers[valhallaType]
+	if !ok {
+		return "continue", "straight"
+	}
+	return kind[0], kind[1]
+}
+
+// convertManeuver converts a Valhalla maneuver into an OSRM step using the decoded leg shape
+func convertManeuver(maneuver valhalla.Maneuver, shape [][]float64) Step {
+	maneuverType, modifier := maneuverKind(maneuver.Type)
+
+	// Clamp the shape indexes so a malformed maneuver cannot panic the conversion
+	begin, end := maneuver.BeginShapeIndex, maneuver.EndShapeIndex
+	if begin < 0 {
+		begin = 0
+	}
+	if end >= len(shape) {
+		end = len(shape) - 1
+	}
+	if begin > end {
+		begin = end
+	}
+
+	var geometry [][]float64
+	var location []float64
+	if end >= 0 {
+		geometry = shape[begin : end+1]
+		location = shape[begin]
+	}
+
+	mode, ok := valhallaTravelModes[maneuver.TravelMode]
+	if !ok {
+		mode = "driving"
+	}
+
+	return Step{
+		Intersections: []Intersection{{
+			Entry:    []bool{true},
+			Bearings: []int{maneuver.BearingAfter},
+			Location: location,
+		}},
+		DrivingSide: "right",
+		Geometry:    &Geometry{Coordinates: geometry, Type: "LineString"},
+		Mode:        mode,
+		Duration:    maneuver.Time,
+		Maneuver: Maneuver{
+			BearingAfter:  maneuver.BearingAfter,
+			Type:          maneuverType,
+			Modifier:      modifier,
+			BearingBefore: maneuver.BearingBefore,
+			Location:      location,
+			Exit:          maneuver.RoundaboutExitCount,
+		},
+		Weight:      maneuver.Cost,
+		Distance:    maneuver.Length,
+		Name:        strings.Join(maneuver.StreetNames, ", "),
+		Instruction: maneuver.Instruction,
+	}
+}
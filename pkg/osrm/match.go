@@ -0,0 +1,117 @@
+package osrm
+
+import (
+	"WayPointPro/pkg/valhalla"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// MatchResponse structure, a nil tracepoint is an input point OSRM dropped as an outlier
+type MatchResponse struct {
+	Code        string        `json:"code"`
+	Message     string        `json:"message,omitempty"`
+	Matchings   []Matching    `json:"matchings"`
+	Tracepoints []*Tracepoint `json:"tracepoints"`
+}
+
+// Matching is a matched sub-route with the engine confidence (0..1)
+type Matching struct {
+	Route
+	Confidence float64 `json:"confidence"`
+}
+
+// Tracepoint is the snapped position of an input point
+type Tracepoint struct {
+	Waypoint
+	MatchingsIndex    int `json:"matchings_index"`
+	WaypointIndex     int `json:"waypoint_index"`
+	AlternativesCount int `json:"alternatives_count"`
+}
+
+// GetMatch snaps a GPS trace to the road network using OSRM /match
+func (s *OSRMService) GetMatch(coordinates string, options map[string]string) (*MatchResponse, error) {
+	url := fmt.Sprintf("%s/match/v1/driving/%s", s.BaseURL, coordinates)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := req.URL.Query()
+	for key, value := range options {
+		if (key == "timestamps" || key == "radiuses" || key == "steps") && value != "" {
+			query.Add(key, value)
+		}
+	}
+	query.Add("overview", "full")
+	query.Add("geometries", "geojson")
+	// Gaps are split by the caller, keep OSRM from splitting again
+	query.Add("gaps", "ignore")
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, &ServerError{StatusCode: resp.StatusCode}
+	}
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	var matchResponse MatchResponse
+	if err := json.Unmarshal(bodyBytes, &matchResponse); err != nil {
+		log.Printf("Error decoding match response: %v", err)
+		return nil, err
+	}
+	if matchResponse.Code != "Ok" {
+		return nil, fmt.Errorf("osrm match error: %s %s", matchResponse.Code, matchResponse.Message)
+	}
+
+	// OSRM reports distances in meters, the rest of the API works in kilometers
+	for i := range matchResponse.Matchings {
+		matchResponse.Matchings[i].Distance /= 1000
+		for j := range matchResponse.Matchings[i].Legs {
+			matchResponse.Matchings[i].Legs[j].Distance /= 1000
+		}
+	}
+
+	return &matchResponse, nil
+}
+
+// ConvertMatchToOSRM converts a Valhalla trace_route trip and its trace_attributes to the OSRM match format
+func (s *OSRMService) ConvertMatchToOSRM(trace *valhalla.RouteResponse, attributes *valhalla.TraceAttributesResponse, steps bool) (*MatchResponse, error) {
+	var matchResponse MatchResponse
+	matchResponse.Code = "Ok"
+
+	route, err := s.ConvertToOSRM(trace, steps)
+	if err != nil {
+		return nil, err
+	}
+	if len(route.Routes) == 0 {
+		return nil, fmt.Errorf("invalid trace route")
+	}
+
+	matching := Matching{Route: route.Routes[0], Confidence: attributes.ConfidenceScore}
+	matchResponse.Matchings = []Matching{matching}
+
+	for i, point := range attributes.MatchedPoints {
+		if point.Type == "unmatched" {
+			matchResponse.Tracepoints = append(matchResponse.Tracepoints, nil)
+			continue
+		}
+		matchResponse.Tracepoints = append(matchResponse.Tracepoints, &Tracepoint{
+			Waypoint: Waypoint{
+				Distance: point.DistanceFromTracePoint,
+				Location: []float64{point.Lon, point.Lat},
+			},
+			WaypointIndex: i,
+		})
+	}
+
+	return &matchResponse, nil
+}
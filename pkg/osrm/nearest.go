@@ -0,0 +1,100 @@
+package osrm
+
+import (
+	"WayPointPro/pkg/valhalla"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+)
+
+// NearestResponse structure
+type NearestResponse struct {
+	Code      string     `json:"code"`
+	Message   string     `json:"message,omitempty"`
+	Waypoints []Waypoint `json:"waypoints"`
+}
+
+// GetNearest snaps a single "lon,lat" coordinate to the closest road using OSRM /nearest,
+// the road class is only known on the Valhalla locate path so it is reported as ClassUnknown
+func (s *OSRMService) GetNearest(coordinate string) (*Waypoint, error) {
+	url := fmt.Sprintf("%s/nearest/v1/driving/%s", s.BaseURL, coordinate)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := req.URL.Query()
+	query.Add("number", "1")
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, &ServerError{StatusCode: resp.StatusCode}
+	}
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	var nearestResponse NearestResponse
+	if err := json.Unmarshal(bodyBytes, &nearestResponse); err != nil {
+		log.Printf("Error decoding nearest response: %v", err)
+		return nil, err
+	}
+	if nearestResponse.Code != "Ok" || len(nearestResponse.Waypoints) == 0 {
+		return nil, fmt.Errorf("osrm nearest error: %s %s", nearestResponse.Code, nearestResponse.Message)
+	}
+
+	waypoint := nearestResponse.Waypoints[0]
+	waypoint.Class = ClassUnknown
+	return &waypoint, nil
+}
+
+// ConvertLocateToOSRM converts Valhalla locate results to OSRM waypoints, unsnapped points stay nil
+func (s *OSRMService) ConvertLocateToOSRM(results []valhalla.LocateResult) []*Waypoint {
+	waypoints := make([]*Waypoint, len(results))
+
+	for i, result := range results {
+		if len(result.Edges) == 0 {
+			continue
+		}
+
+		// Valhalla lists every candidate edge, keep the closest one
+		var best *Waypoint
+		for _, edge := range result.Edges {
+			distance := haversine(result.InputLon, result.InputLat, edge.CorrelatedLon, edge.CorrelatedLat)
+			if best != nil && distance >= best.Distance {
+				continue
+			}
+			name := ""
+			if len(edge.EdgeInfo.Names) > 0 {
+				name = edge.EdgeInfo.Names[0]
+			}
+			best = &Waypoint{
+				Distance: distance,
+				Name:     name,
+				Location: []float64{edge.CorrelatedLon, edge.CorrelatedLat},
+				Class:    edge.Edge.Classification.Classification,
+			}
+		}
+		waypoints[i] = best
+	}
+
+	return waypoints
+}
+
+// haversine returns the distance in meters between two lon/lat points
+func haversine(lon1, lat1, lon2, lat2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 6371000 * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
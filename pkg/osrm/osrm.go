@@ -2,23 +2,60 @@ package osrm
 
 import (
 	"WayPointPro/internal/config"
+	"WayPointPro/pkg/polyline"
 	"WayPointPro/pkg/valhalla"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"time"
 )
 
 type OSRMService struct {
-	BaseURL string
+	BaseURL  string
+	Profiles map[string]Profile
 }
 
 type RouteResponse struct {
-	Code      string           `json:"code"`
-	Routes    []Route          `json:"routes"`
-	Waypoints []Waypoint       `json:"waypoints"`
-	Summary   valhalla.Summary `json:"summary"`
+	Code        string           `json:"code"`
+	Message     string           `json:"message,omitempty"`
+	Routes      []Route          `json:"routes"`
+	Waypoints   []Waypoint       `json:"waypoints"`
+	Summary     valhalla.Summary `json:"summary"`
+	Preferences *Preferences     `json:"preferences,omitempty"`
+	Traffic     *TrafficSnapshot `json:"traffic,omitempty"`
+	Calibration *ETACalibration  `json:"calibration,omitempty"`
+	Engine      string           `json:"engine,omitempty"`
+}
+
+// TrafficSnapshot describes the traffic a route was timed with.
+// Source is live, historical, profile, mixed, valhalla or none, Bucket is the traffic_data bucket used.
+// Tiles counts the tiles of a live fetch by source: live, profile when the live tile was unavailable, or missing
+type TrafficSnapshot struct {
+	Source   string         `json:"source"`
+	Bucket   string         `json:"bucket,omitempty"`
+	Tiles    map[string]int `json:"tiles,omitempty"`
+	DepartAt string         `json:"depart_at,omitempty"`
+	ArriveBy string         `json:"arrive_by,omitempty"`
+}
+
+// ETACalibration is the coefficient the durations were calibrated with, (duration * multiplier + offset) * correction.
+// The correction is learned from trip actuals, 1 until enough trips were reported. EngineDuration is the ETA
+// of the first route as the engine returned it, before calibration
+type ETACalibration struct {
+	Region         string  `json:"region"`
+	Bucket         string  `json:"bucket"`
+	Multiplier     float64 `json:"multiplier"`
+	Offset         float64 `json:"offset_seconds"`
+	Correction     float64 `json:"correction"`
+	EngineDuration float64 `json:"engine_duration"`
+}
+
+// Preferences lists the requested routing preferences the engine honored and the ones it could not apply
+type Preferences struct {
+	Honored    []string `json:"honored"`
+	NotApplied []string `json:"not_applied"`
 }
 
 type Route struct {
@@ -38,26 +75,28 @@ type Geometry struct {
 
 // Leg structure
 type Leg struct {
-	Steps     []Step              `json:"steps"`
-	Distance  float64             `json:"distance"`
-	Duration  float64             `json:"duration"`
-	Weight    float64             `json:"weight"`
-	Maneuvers []valhalla.Maneuver `json:"maneuvers"`
-	Summary   valhalla.Summary    `json:"summary"`
-	Shape     string              `json:"shape,omitempty"`
+	Steps    []Step           `json:"steps"`
+	Distance float64          `json:"distance"`
+	Duration float64          `json:"duration"`
+	Weight   float64          `json:"weight"`
+	Summary  valhalla.Summary `json:"summary"`
+	Shape    string           `json:"shape,omitempty"`
+	Geometry *Geometry        `json:"geometry,omitempty"`
 }
 
 // Step structure
 type Step struct {
 	Intersections []Intersection `json:"intersections"`
 	DrivingSide   string         `json:"driving_side"`
-	Geometry      Geometry       `json:"geometry"`
+	Shape         string         `json:"shape,omitempty"`
+	Geometry      *Geometry      `json:"geometry,omitempty"`
 	Mode          string         `json:"mode"`
 	Duration      float64        `json:"duration"`
 	Maneuver      Maneuver       `json:"maneuver"`
 	Weight        float64        `json:"weight"`
 	Distance      float64        `json:"distance"`
 	Name          string         `json:"name"`
+	Instruction   string         `json:"instruction,omitempty"`
 }
 
 // Intersection structure
@@ -76,21 +115,40 @@ type Maneuver struct {
 	Modifier      string    `json:"modifier"`
 	BearingBefore int       `json:"bearing_before"`
 	Location      []float64 `json:"location"`
+	Exit          int       `json:"exit,omitempty"`
+}
+
+// ServerError is an OSRM answer with a 5xx status, the instance failed rather than the request
+type ServerError struct {
+	StatusCode int
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("osrm server error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
 }
 
+// ClassUnknown is the road class of waypoints snapped by OSRM, its /nearest response carries no road class
+const ClassUnknown = "unknown"
+
 type Waypoint struct {
 	Hint     string    `json:"hint"`
 	Distance float64   `json:"distance"`
 	Name     string    `json:"name"`
 	Location []float64 `json:"location"`
+	Class    string    `json:"class,omitempty"`
 }
 
 func NewOSRMService() *OSRMService {
-	return &OSRMService{BaseURL: config.LoadConfig().OSRMHost}
+	cfg := config.LoadConfig()
+	return &OSRMService{BaseURL: cfg.OSRMHost, Profiles: newProfiles(cfg)}
 }
 
 func (s *OSRMService) GetRoute(coordinates string, options map[string]string) (*RouteResponse, error) {
-	url := fmt.Sprintf("%s/route/v1/driving/%s", s.BaseURL, coordinates)
+	profile, err := s.profile(options["mode"])
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/route/v1/%s/%s", profile.Host, profile.Name, coordinates)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -102,6 +160,12 @@ func (s *OSRMService) GetRoute(coordinates string, options map[string]string) (*
 		if key == "overview" || key == "geometries" || key == "steps" {
 			query.Add(key, value)
 		}
+		if key == "alternates" && value != "" && value != "false" {
+			query.Add("alternatives", "3")
+		}
+		if (key == "exclude" || key == "bearings" || key == "radiuses" || key == "approaches" || key == "hints" || key == "waypoints") && value != "" {
+			query.Add(key, value)
+		}
 	}
 	req.URL.RawQuery = query.Encode()
 	//log.Printf("reqesut url: %s, Legs: %s", req.URL)
@@ -111,6 +175,9 @@ func (s *OSRMService) GetRoute(coordinates string, options map[string]string) (*
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, &ServerError{StatusCode: resp.StatusCode}
+	}
 
 	// Log raw response
 	bodyBytes, _ := io.ReadAll(resp.Body)
@@ -126,8 +193,8 @@ func (s *OSRMService) GetRoute(coordinates string, options map[string]string) (*
 	return &routeResponse, nil
 }
 
-// Function to convert Valhalla response to OSRM format
-func (s *OSRMService) ConvertToOSRM(valhallaResponse *valhalla.RouteResponse) (*RouteResponse, error) {
+// Function to convert Valhalla response to OSRM format, the maneuvers become steps only when steps are requested
+func (s *OSRMService) ConvertToOSRM(valhallaResponse *valhalla.RouteResponse, steps bool) (*RouteResponse, error) {
 	var osrmResponse RouteResponse
 	osrmResponse.Code = "Ok"
 	osrmResponse.Summary = valhallaResponse.Trip.Summary
@@ -140,42 +207,70 @@ func (s *OSRMService) ConvertToOSRM(valhallaResponse *valhalla.RouteResponse) (*
 		})
 	}
 
-	// Convert legs and geometry
+	// The primary trip and every alternate become separate routes
+	osrmResponse.Routes = append(osrmResponse.Routes, s.convertTrip(valhallaResponse.Trip, steps))
+	for _, alternate := range valhallaResponse.Alternates {
+		osrmResponse.Routes = append(osrmResponse.Routes, s.convertTrip(alternate.Trip, steps))
+	}
+
+	return &osrmResponse, nil
+}
+
+// convertTrip converts one Valhalla trip into an OSRM route
+func (s *OSRMService) convertTrip(trip valhalla.Trip, steps bool) Route {
 	var route Route
 	route.WeightName = "routability"
 
-	for _, leg := range valhallaResponse.Trip.Legs {
+	route.Geometry = Geometry{Type: "LineString"}
+
+	for _, leg := range trip.Legs {
+		// Valhalla encodes leg shapes as polyline6
+		shape := polyline.Decode(leg.Shape, polyline.Precision6)
+
 		var osrmLeg Leg
 		osrmLeg.Distance = leg.Summary.Length
 		osrmLeg.Duration = leg.Summary.Time
 		osrmLeg.Weight = leg.Summary.Cost
 		osrmLeg.Summary = leg.Summary
-		osrmLeg.Maneuvers = leg.Maneuvers
-		osrmLeg.Shape = leg.Shape
-		route.Geometry = Geometry{}
+		osrmLeg.Geometry = &Geometry{Coordinates: shape, Type: "LineString"}
+		if steps {
+			for _, maneuver := range leg.Maneuvers {
+				osrmLeg.Steps = append(osrmLeg.Steps, convertManeuver(maneuver, shape))
+			}
+		}
 		route.Legs = append(route.Legs, osrmLeg)
+
+		// Consecutive legs share their joining point
+		if len(route.Geometry.Coordinates) > 0 && len(shape) > 0 {
+			shape = shape[1:]
+		}
+		route.Geometry.Coordinates = append(route.Geometry.Coordinates, shape...)
 	}
 
 	// Compute overall distance and duration
 	for _, leg := range route.Legs {
 		route.Distance += leg.Distance
 		route.Duration += leg.Duration
+		route.Weight += leg.Weight
 	}
 
-	for _, alternate := range valhallaResponse.Alternates {
-		for _, leg := range alternate.Trip.Legs {
-			var osrmLeg Leg
-			osrmLeg.Distance = leg.Summary.Length
-			osrmLeg.Duration = leg.Summary.Time
-			osrmLeg.Weight = leg.Summary.Cost
-			osrmLeg.Summary = leg.Summary
-			osrmLeg.Maneuvers = leg.Maneuvers
-			osrmLeg.Shape = leg.Shape
-			route.Geometry = Geometry{}
-			route.Legs = append(route.Legs, osrmLeg)
-		}
+	return route
+}
+
+// Health probes the OSRM instance with a /nearest request, any well formed OSRM answer means it is up
+func (s *OSRMService) Health() error {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("%s/nearest/v1/driving/0,0", s.BaseURL))
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	osrmResponse.Routes = append(osrmResponse.Routes, route)
-	return &osrmResponse, nil
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Code == "" {
+		return fmt.Errorf("unexpected OSRM response, status %d", resp.StatusCode)
+	}
+	return nil
 }
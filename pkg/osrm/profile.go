@@ -0,0 +1,36 @@
+package osrm
+
+import (
+	"WayPointPro/internal/config"
+	"fmt"
+)
+
+// Profile is an OSRM instance serving one travel mode
+type Profile struct {
+	Host string
+	Name string
+}
+
+// newProfiles maps the travel modes to their OSRM instances, car uses the default host.
+// The truck instance has no per-request limits, routes ignore height, width, weight, axle load and hazmat
+func newProfiles(cfg *config.Config) map[string]Profile {
+	return map[string]Profile{
+		"car":        {Host: cfg.OSRMHost, Name: "driving"},
+		"truck":      {Host: cfg.OSRMTruckHost, Name: "driving"},
+		"motorcycle": {Host: cfg.OSRMMotorcycleHost, Name: "driving"},
+		"bicycle":    {Host: cfg.OSRMBicycleHost, Name: "cycling"},
+		"pedestrian": {Host: cfg.OSRMPedestrianHost, Name: "walking"},
+	}
+}
+
+// profile returns the OSRM instance of a travel mode, an empty mode is car
+func (s *OSRMService) profile(mode string) (Profile, error) {
+	if mode == "" {
+		return Profile{Host: s.BaseURL, Name: "driving"}, nil
+	}
+	profile, ok := s.Profiles[mode]
+	if !ok || profile.Host == "" {
+		return Profile{}, fmt.Errorf("no OSRM profile configured for mode %q", mode)
+	}
+	return profile, nil
+}
@@ -0,0 +1,118 @@
+package osrm
+
+import (
+	"WayPointPro/pkg/valhalla"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TableResponse structure, cells are nil when OSRM could not find a route
+type TableResponse struct {
+	Code         string       `json:"code"`
+	Message      string       `json:"message,omitempty"`
+	Durations    [][]*float64 `json:"durations"`
+	Distances    [][]*float64 `json:"distances"`
+	Sources      []Waypoint   `json:"sources"`
+	Destinations []Waypoint   `json:"destinations"`
+	Engine       string       `json:"engine,omitempty"`
+}
+
+// GetTable requests a many-to-many duration/distance table from OSRM
+func (s *OSRMService) GetTable(sources, destinations string, options map[string]string) (*TableResponse, error) {
+	sourceCount := len(strings.Split(sources, ";"))
+	destinationCount := len(strings.Split(destinations, ";"))
+
+	url := fmt.Sprintf("%s/table/v1/driving/%s;%s", s.BaseURL, sources, destinations)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := req.URL.Query()
+	query.Add("sources", joinIndexes(0, sourceCount))
+	query.Add("destinations", joinIndexes(sourceCount, destinationCount))
+	query.Add("annotations", "duration,distance")
+	for key, value := range options {
+		if key == "fallback_speed" && value != "" {
+			query.Add(key, value)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, &ServerError{StatusCode: resp.StatusCode}
+	}
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	var tableResponse TableResponse
+	if err := json.Unmarshal(bodyBytes, &tableResponse); err != nil {
+		log.Printf("Error decoding table response: %v", err)
+		return nil, err
+	}
+	if tableResponse.Code != "Ok" {
+		return nil, fmt.Errorf("osrm table error: %s %s", tableResponse.Code, tableResponse.Message)
+	}
+
+	// OSRM reports distances in meters, the rest of the API works in kilometers
+	for _, row := range tableResponse.Distances {
+		for j, distance := range row {
+			if distance != nil {
+				km := *distance / 1000
+				row[j] = &km
+			}
+		}
+	}
+
+	return &tableResponse, nil
+}
+
+// ConvertMatrixToOSRM converts a Valhalla sources_to_targets response to the OSRM table format
+func (s *OSRMService) ConvertMatrixToOSRM(matrix *valhalla.MatrixResponse) (*TableResponse, error) {
+	var tableResponse TableResponse
+	tableResponse.Code = "Ok"
+
+	for _, loc := range matrix.Sources {
+		tableResponse.Sources = append(tableResponse.Sources, Waypoint{
+			Location: []float64{loc.Lon, loc.Lat},
+		})
+	}
+	for _, loc := range matrix.Targets {
+		tableResponse.Destinations = append(tableResponse.Destinations, Waypoint{
+			Location: []float64{loc.Lon, loc.Lat},
+		})
+	}
+
+	tableResponse.Durations = make([][]*float64, len(matrix.SourcesToTargets))
+	tableResponse.Distances = make([][]*float64, len(matrix.SourcesToTargets))
+	for i, row := range matrix.SourcesToTargets {
+		tableResponse.Durations[i] = make([]*float64, len(row))
+		tableResponse.Distances[i] = make([]*float64, len(row))
+		for j, cell := range row {
+			tableResponse.Durations[i][j] = cell.Time
+			tableResponse.Distances[i][j] = cell.Distance
+		}
+	}
+
+	return &tableResponse, nil
+}
+
+// joinIndexes builds an OSRM index list such as "0;1;2"
+func joinIndexes(start, count int) string {
+	indexes := make([]string, count)
+	for i := 0; i < count; i++ {
+		indexes[i] = strconv.Itoa(start + i)
+	}
+	return strings.Join(indexes, ";")
+}
@@ -0,0 +1,65 @@
+package osrm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// TripResponse structure
+type TripResponse struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message,omitempty"`
+	Trips     []Route        `json:"trips"`
+	Waypoints []TripWaypoint `json:"waypoints"`
+}
+
+// TripWaypoint is a Waypoint with its position inside the optimized trip
+type TripWaypoint struct {
+	Waypoint
+	WaypointIndex int `json:"waypoint_index"`
+	TripsIndex    int `json:"trips_index"`
+}
+
+// GetTrip requests a travelling-salesman ordering of the coordinates from OSRM
+func (s *OSRMService) GetTrip(coordinates string, options map[string]string) (*TripResponse, error) {
+	url := fmt.Sprintf("%s/trip/v1/driving/%s", s.BaseURL, coordinates)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := req.URL.Query()
+	for key, value := range options {
+		if (key == "roundtrip" || key == "source" || key == "destination") && value != "" {
+			query.Add(key, value)
+		}
+	}
+	query.Add("overview", "false")
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, &ServerError{StatusCode: resp.StatusCode}
+	}
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	var tripResponse TripResponse
+	if err := json.Unmarshal(bodyBytes, &tripResponse); err != nil {
+		log.Printf("Error decoding trip response: %v", err)
+		return nil, err
+	}
+	if tripResponse.Code != "Ok" {
+		return nil, fmt.Errorf("osrm trip error: %s %s", tripResponse.Code, tripResponse.Message)
+	}
+
+	return &tripResponse, nil
+}
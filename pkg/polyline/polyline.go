@@ -0,0 +1,83 @@
+package polyline
+
+import "math"
+
+// Precisions used by the routing engines: OSRM polyline and Valhalla polyline6
+const (
+	Precision5 = 5
+	Precision6 = 6
+)
+
+// Decode decodes an encoded polyline into [lon, lat] coordinates
+func Decode(encoded string, precision int) [][]float64 {
+	factor := math.Pow(10, float64(precision))
+	coordinates := make([][]float64, 0, len(encoded)/4)
+
+	index, lat, lon := 0, 0, 0
+	for index < len(encoded) {
+		var deltaLat, deltaLon int
+		var ok bool
+		if deltaLat, index, ok = decodeValue(encoded, index); !ok {
+			break
+		}
+		if deltaLon, index, ok = decodeValue(encoded, index); !ok {
+			break
+		}
+		lat += deltaLat
+		lon += deltaLon
+		coordinates = append(coordinates, []float64{float64(lon) / factor, float64(lat) / factor})
+	}
+
+	return coordinates
+}
+
+// decodeValue reads one zigzag encoded varint starting at index
+func decodeValue(encoded string, index int) (int, int, bool) {
+	result, shift := 0, uint(0)
+	for index < len(encoded) {
+		b := int(encoded[index]) - 63
+		index++
+		result |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			if result&1 != 0 {
+				return ^(result >> 1), index, true
+			}
+			return result >> 1, index, true
+		}
+	}
+	return 0, index, false
+}
+
+// Encode encodes [lon, lat] coordinates into a polyline of the given precision
+func Encode(coordinates [][]float64, precision int) string {
+	factor := math.Pow(10, float64(precision))
+	encoded := make([]byte, 0, len(coordinates)*6)
+
+	previousLat, previousLon := 0, 0
+	for _, point := range coordinates {
+		if len(point) < 2 {
+			continue
+		}
+		lat := int(math.Round(point[1] * factor))
+		lon := int(math.Round(point[0] * factor))
+		encoded = encodeValue(encoded, lat-previousLat)
+		encoded = encodeValue(encoded, lon-previousLon)
+		previousLat, previousLon = lat, lon
+	}
+
+	return string(encoded)
+}
+
+// encodeValue appends one zigzag encoded varint
+func encodeValue(encoded []byte, value int) []byte {
+	shifted := value << 1
+	if value < 0 {
+		shifted = ^shifted
+	}
+	for shifted >= 0x20 {
+		encoded = append(encoded, byte((0x20|(shifted&0x1f))+63))
+		shifted >>= 5
+	}
+	return append(encoded, byte(shifted+63))
+}
@@ -19,7 +19,7 @@ func CollectTrafficJobHandle() {
 	}
 
 	service := traffic.NewService()
-	_, err := service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err := service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for maccah
 	boundingBox = map[string]float64{
@@ -29,7 +29,7 @@ func CollectTrafficJobHandle() {
 		"east":  40.03, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for ryadih
 	boundingBox = map[string]float64{
@@ -39,7 +39,7 @@ func CollectTrafficJobHandle() {
 		"east":  47.03, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for Madinah
 	boundingBox = map[string]float64{
@@ -49,7 +49,7 @@ func CollectTrafficJobHandle() {
 		"east":  39.75, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for Dammam
 	boundingBox = map[string]float64{
@@ -59,7 +59,7 @@ func CollectTrafficJobHandle() {
 		"east":  50.20, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for Tabuk
 	boundingBox = map[string]float64{
@@ -69,7 +69,7 @@ func CollectTrafficJobHandle() {
 		"east":  36.90, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for Buraydah
 	boundingBox = map[string]float64{
@@ -79,7 +79,7 @@ func CollectTrafficJobHandle() {
 		"east":  44.10, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for Abha
 	boundingBox = map[string]float64{
@@ -89,7 +89,7 @@ func CollectTrafficJobHandle() {
 		"east":  42.55, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for Taif
 	boundingBox = map[string]float64{
@@ -99,7 +99,7 @@ func CollectTrafficJobHandle() {
 		"east":  40.45, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for Hofuf
 	boundingBox = map[string]float64{
@@ -109,7 +109,7 @@ func CollectTrafficJobHandle() {
 		"east":  49.65, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for Qatif
 	boundingBox = map[string]float64{
@@ -119,7 +119,7 @@ func CollectTrafficJobHandle() {
 		"east":  50.20, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	// Define the bounding box for Khobar
 	boundingBox = map[string]float64{
@@ -129,7 +129,7 @@ func CollectTrafficJobHandle() {
 		"east":  50.20, // Easternmost longitude
 	}
 
-	_, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
+	_, _, err = service.FetchAndAnalyzeTraffic(boundingBox, 11, true)
 
 	logging.Logger.Println(err)
 	//Initialize the database singleton
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"WayPointPro/pkg/eta"
+	"WayPointPro/pkg/traffic"
+	"log"
+	"time"
+)
+
+// Trips older than this are left out of the fit
+const etaFitWindow = 30 * 24 * time.Hour
+
+// FitETACorrections job fits the per-region and per-hour ETA correction factors from the reported trip actuals,
+// drops the corrections of buckets left with too few trips and the route quotes no trip can be reported against anymore
+func FitETACorrections() {
+	var cache = traffic.NewCache()
+	startedAt := time.Now()
+
+	rows, err := cache.DB.Query(cache.CTX, `
+		SELECT route_id, region, quoted_duration, calibrated_duration, engine_duration, quoted_distance,
+			actual_duration, actual_distance, started_at, ended_at
+		FROM trip_actuals
+		WHERE started_at >= $1`, time.Now().Add(-etaFitWindow))
+	if err != nil {
+		log.Println("Failed to load trip actuals:", err)
+		return
+	}
+	var actuals []eta.TripActual
+	for rows.Next() {
+		var actual eta.TripActual
+		if err := rows.Scan(&actual.RouteID, &actual.Region, &actual.QuotedDuration, &actual.CalibratedDuration,
+			&actual.EngineDuration, &actual.QuotedDistance, &actual.ActualDuration, &actual.ActualDistance, &actual.StartedAt, &actual.EndedAt); err != nil {
+			rows.Close()
+			log.Println("Failed to read trip actual:", err)
+			return
+		}
+		actuals = append(actuals, actual)
+	}
+	rows.Close()
+
+	corrections := eta.FitCorrections(actuals)
+	query := `
+		INSERT INTO eta_corrections (region, hour, factor, samples, held_out, mae_before, bias_before, mae_after, bias_after, fitted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (region, hour)
+		DO UPDATE SET
+			factor = EXCLUDED.factor,
+			samples = EXCLUDED.samples,
+			held_out = EXCLUDED.held_out,
+			mae_before = EXCLUDED.mae_before,
+			bias_before = EXCLUDED.bias_before,
+			mae_after = EXCLUDED.mae_after,
+			bias_after = EXCLUDED.bias_after,
+			fitted_at = EXCLUDED.fitted_at
+	`
+	for _, correction := range corrections {
+		_, err := cache.DB.Exec(cache.CTX, query, correction.Region, correction.Hour, correction.Factor, correction.Samples,
+			correction.HeldOut, correction.MAEBefore, correction.BiasBefore, correction.MAEAfter, correction.BiasAfter, correction.FittedAt)
+		if err != nil {
+			log.Println("Failed to save ETA correction:", err)
+			return
+		}
+	}
+	log.Printf("Fitted %d ETA corrections from %d trips", len(corrections), len(actuals))
+
+	// Buckets that dropped below the minimum trips were not fitted again, their old factor must not linger
+	dropped, err := cache.DB.Exec(cache.CTX, `DELETE FROM eta_corrections WHERE fitted_at < $1`, startedAt)
+	if err != nil {
+		log.Println("Failed to drop stale ETA corrections:", err)
+	} else if dropped.RowsAffected() > 0 {
+		log.Printf("Dropped %d stale ETA corrections", dropped.RowsAffected())
+	}
+
+	if _, err := cache.DB.Exec(cache.CTX, `DELETE FROM route_quotes WHERE created_at < $1`,
+		time.Now().Add(-eta.QuoteRetention)); err != nil {
+		log.Println("Failed to prune route quotes:", err)
+	}
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"WayPointPro/pkg/traffic"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Speed samples older than this are left out of the profiles and deleted
+const speedProfileWindow = 8 * 7 * 24 * time.Hour
+
+// A bucket needs this many samples before its profile is trusted
+const minSpeedProfileSamples = 3
+
+var (
+	speedSamplesMu    sync.Mutex
+	speedSamplesSince = time.Now().Add(-15 * time.Minute)
+)
+
+// CollectSpeedSamples job copies the segment speeds of the tiles fetched since the last run into traffic_speed_samples
+func CollectSpeedSamples() {
+	speedSamplesMu.Lock()
+	defer speedSamplesMu.Unlock()
+
+	var cache = traffic.NewCache()
+	runStart := time.Now()
+
+	rows, err := cache.DB.Query(cache.CTX, `
+		SELECT tile_z, tile_x, tile_y, day_of_week, hour, minute, traffic_data::text, updated_at
+		FROM traffic_data
+		WHERE updated_at >= $1`, speedSamplesSince)
+	if err != nil {
+		log.Println("Failed to load traffic tiles:", err)
+		return
+	}
+
+	var samples [][]interface{}
+	invalid := 0
+	for rows.Next() {
+		var z, x, y, hour, minute int
+		var dayOfWeek, data string
+		var updatedAt time.Time
+		if err := rows.Scan(&z, &x, &y, &dayOfWeek, &hour, &minute, &data, &updatedAt); err != nil {
+			rows.Close()
+			log.Println("Failed to read traffic tile:", err)
+			return
+		}
+		features, skipped, err := traffic.ParseTrafficData([]byte(data))
+		invalid += skipped
+		if err != nil {
+			invalid++
+			continue
+		}
+		for _, sample := range traffic.SpeedSamples(features) {
+			samples = append(samples, []interface{}{z, x, y, sample.Segment, sample.Class, dayOfWeek, hour, minute,
+				sample.SpeedRatio, sample.SpeedKmh, updatedAt})
+		}
+	}
+	rows.Close()
+
+	_, err = cache.DB.CopyFrom(cache.CTX, pgx.Identifier{"traffic_speed_samples"},
+		[]string{"tile_z", "tile_x", "tile_y", "segment", "class", "day_of_week", "hour", "minute", "speed_ratio", "speed_kmh", "recorded_at"},
+		pgx.CopyFromRows(samples))
+	if err != nil {
+		log.Println("Failed to save speed samples:", err)
+		return
+	}
+	speedSamplesSince = runStart
+	log.Printf("Collected %d speed samples, skipped %d invalid features", len(samples), invalid)
+}
+
+// BuildSpeedProfiles job aggregates the samples of the last weeks into the median and 85th percentile speed
+// of every segment per weekday and 15-minute bucket, then drops the samples that fell out of the window
+func BuildSpeedProfiles() {
+	var cache = traffic.NewCache()
+	since := time.Now().Add(-speedProfileWindow)
+
+	query := `
+		INSERT INTO traffic_speed_profiles (tile_z, tile_x, tile_y, segment, day_of_week, hour, minute, class,
+			median_speed, p85_speed, median_ratio, samples, updated_at)
+		SELECT tile_z, tile_x, tile_y, segment, day_of_week, hour, minute, MIN(class),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY speed_kmh),
+			percentile_cont(0.85) WITHIN GROUP (ORDER BY speed_kmh),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY speed_ratio),
+			COUNT(*), NOW()
+		FROM traffic_speed_samples
+		WHERE recorded_at >= $1
+		GROUP BY tile_z, tile_x, tile_y, segment, day_of_week, hour, minute
+		HAVING COUNT(*) >= $2
+		ON CONFLICT (tile_z, tile_x, tile_y, segment, day_of_week, hour, minute)
+		DO UPDATE SET
+			class = EXCLUDED.class,
+			median_speed = EXCLUDED.median_speed,
+			p85_speed = EXCLUDED.p85_speed,
+			median_ratio = EXCLUDED.median_ratio,
+			samples = EXCLUDED.samples,
+			updated_at = EXCLUDED.updated_at
+	`
+	result, err := cache.DB.Exec(cache.CTX, query, since, minSpeedProfileSamples)
+	if err != nil {
+		log.Println("Failed to build speed profiles:", err)
+		return
+	}
+	log.Printf("Built %d speed profiles", result.RowsAffected())
+
+	if _, err := cache.DB.Exec(cache.CTX, `DELETE FROM traffic_speed_samples WHERE recorded_at < $1`, since); err != nil {
+		log.Println("Failed to delete old speed samples:", err)
+	}
+}
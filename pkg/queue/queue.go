@@ -21,8 +21,13 @@ type Queue struct {
 
 // NewQueue creates a new job queue
 func NewQueue(workers int) *Queue {
+	return NewQueueSize(workers, 100) // Buffer size of 100 jobs
+}
+
+// NewQueueSize creates a new job queue holding at most size pending jobs
+func NewQueueSize(workers, size int) *Queue {
 	return &Queue{
-		JobQueue: make(chan Job, 100), // Buffer size of 100 jobs
+		JobQueue: make(chan Job, size),
 		Workers:  workers,
 	}
 }
@@ -46,6 +51,18 @@ func (q *Queue) AddJob(job Job) {
 	q.JobQueue <- job
 }
 
+// TryAddJob adds a job to the queue without blocking, it reports false when the queue is full
+func (q *Queue) TryAddJob(job Job) bool {
+	q.wg.Add(1)
+	select {
+	case q.JobQueue <- job:
+		return true
+	default:
+		q.wg.Done()
+		return false
+	}
+}
+
 // Wait waits for all jobs to be processed
 func (q *Queue) Wait() {
 	q.wg.Wait()
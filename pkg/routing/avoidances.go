@@ -0,0 +1,106 @@
+package routing
+
+import (
+	"WayPointPro/pkg/osrm"
+	"strconv"
+	"strings"
+)
+
+// Avoidance preferences accepted in the route options
+const (
+	AvoidTolls      = "avoid_tolls"
+	AvoidHighways   = "avoid_highways"
+	AvoidFerries    = "avoid_ferries"
+	ExcludePolygons = "exclude_polygons"
+)
+
+// OSRM exclude classes of the avoidance preferences, they only work when the profile defines them
+var osrmExcludeClasses = map[string]string{
+	AvoidTolls:    "toll",
+	AvoidHighways: "motorway",
+	AvoidFerries:  "ferry",
+}
+
+// RequestedAvoidances returns the avoidance preferences set in the options in a stable order
+func RequestedAvoidances(options map[string]string) []string {
+	var requested []string
+	for _, key := range []string{AvoidTolls, AvoidHighways, AvoidFerries} {
+		if options[key] == "true" {
+			requested = append(requested, key)
+		}
+	}
+	if options[ExcludePolygons] != "" {
+		requested = append(requested, ExcludePolygons)
+	}
+	return requested
+}
+
+// Truck limits accepted in the route options, only Valhalla routes with them
+var truckLimits = []string{"truck_height", "truck_width", "truck_weight", "truck_axle_load", "truck_hazmat"}
+
+// RequestedTruckLimits returns the truck limits set in the options in a stable order
+func RequestedTruckLimits(options map[string]string) []string {
+	var requested []string
+	for _, key := range truckLimits {
+		value, _ := strconv.ParseFloat(options[key], 64)
+		if value > 0 || options[key] == "true" {
+			requested = append(requested, key)
+		}
+	}
+	return requested
+}
+
+// osrmExclude builds the OSRM exclude parameter for the requested avoidances
+func osrmExclude(requested []string) string {
+	var classes []string
+	for _, key := range requested {
+		if class, ok := osrmExcludeClasses[key]; ok {
+			classes = append(classes, class)
+		}
+	}
+	return strings.Join(classes, ",")
+}
+
+// valhallaPreferences checks the trip summary, Valhalla only penalizes avoided roads
+// so a route that still has no other option will use them
+func valhallaPreferences(requested []string, route *osrm.RouteResponse) *osrm.Preferences {
+	if len(requested) == 0 {
+		return nil
+	}
+
+	used := map[string]bool{
+		AvoidTolls:    route.Summary.HasToll,
+		AvoidHighways: route.Summary.HasHighway,
+		AvoidFerries:  route.Summary.HasFerry,
+	}
+
+	preferences := &osrm.Preferences{Honored: []string{}, NotApplied: []string{}}
+	for _, key := range requested {
+		if used[key] {
+			preferences.NotApplied = append(preferences.NotApplied, key)
+		} else {
+			preferences.Honored = append(preferences.Honored, key)
+		}
+	}
+	return preferences
+}
+
+// osrmPreferences reports the exclude classes as honored when OSRM accepted them,
+// custom areas and truck limits are never applied because OSRM has no runtime polygon
+// exclusion and its truck profile is built without them
+func osrmPreferences(requested []string, excluded bool, limits []string) *osrm.Preferences {
+	if len(requested) == 0 && len(limits) == 0 {
+		return nil
+	}
+
+	preferences := &osrm.Preferences{Honored: []string{}, NotApplied: []string{}}
+	for _, key := range requested {
+		if _, ok := osrmExcludeClasses[key]; ok && excluded {
+			preferences.Honored = append(preferences.Honored, key)
+		} else {
+			preferences.NotApplied = append(preferences.NotApplied, key)
+		}
+	}
+	preferences.NotApplied = append(preferences.NotApplied, limits...)
+	return preferences
+}
@@ -0,0 +1,106 @@
+package routing
+
+import (
+	"WayPointPro/internal/config"
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/valhalla"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// Routing engines
+const (
+	EngineOSRM     = "osrm"
+	EngineValhalla = "valhalla"
+)
+
+// RoutingEngine is a routing backend, every call answers in the OSRM format with distances in kilometers
+type RoutingEngine interface {
+	Name() string
+	Route(coordinates string, options map[string]string) (*osrm.RouteResponse, error)
+	Matrix(sources, destinations string, options map[string]string) (*osrm.TableResponse, error)
+	// Nearest returns one waypoint per coordinate, nil when the point cannot be snapped
+	Nearest(coordinates string, options map[string]string) ([]*osrm.Waypoint, error)
+	Match(points []models.TracePoint, options map[string]string) (*osrm.MatchResponse, error)
+	Health() error
+}
+
+// Unavailable reports whether an engine error comes from the engine rather than the request:
+// the engine could not be reached or answered with a server error
+func Unavailable(err error) bool {
+	var transportErr *url.Error
+	var osrmErr *osrm.ServerError
+	var valhallaErr *valhalla.ServerError
+	return errors.As(err, &transportErr) || errors.As(err, &osrmErr) || errors.As(err, &valhallaErr)
+}
+
+// Registry holds the available engines, the primary engine first
+type Registry struct {
+	mu      sync.RWMutex
+	engines map[string]RoutingEngine
+	order   []string
+}
+
+var (
+	registryInstance *Registry
+	registryOnce     sync.Once
+)
+
+// SharedRegistry returns the process wide registry built from the configuration
+func SharedRegistry() *Registry {
+	registryOnce.Do(func() {
+		registryInstance = NewRegistry(config.LoadConfig())
+	})
+	return registryInstance
+}
+
+// NewRegistry registers OSRM and Valhalla, PLATFORM=OSRM makes OSRM the primary engine
+func NewRegistry(cfg *config.Config) *Registry {
+	if cfg.PLATFORM == "OSRM" {
+		return NewRegistryOf(NewOSRMEngine(), NewValhallaEngine())
+	}
+	return NewRegistryOf(NewValhallaEngine(), NewOSRMEngine())
+}
+
+// NewRegistryOf registers the given engines, the first one is the primary engine
+func NewRegistryOf(engines ...RoutingEngine) *Registry {
+	registry := &Registry{engines: map[string]RoutingEngine{}}
+	for _, engine := range engines {
+		registry.Register(engine)
+	}
+	return registry
+}
+
+// Register adds an engine after the ones already registered, an engine with the same name is replaced in place
+func (r *Registry) Register(engine RoutingEngine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.engines[engine.Name()]; !ok {
+		r.order = append(r.order, engine.Name())
+	}
+	r.engines[engine.Name()] = engine
+}
+
+// Get returns an engine by name
+func (r *Registry) Get(name string) (RoutingEngine, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	engine, ok := r.engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown routing engine %q", name)
+	}
+	return engine, nil
+}
+
+// Names returns the registered engine names, primary first
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.order...)
+}
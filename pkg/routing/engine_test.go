@@ -0,0 +1,28 @@
+package routing
+
+import (
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/valhalla"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestUnavailable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&url.Error{Op: "Get", URL: "http://osrm", Err: errors.New("timeout")}, true},
+		{&osrm.ServerError{StatusCode: http.StatusBadGateway}, true},
+		{&valhalla.ServerError{StatusCode: http.StatusInternalServerError}, true},
+		{errors.New("invalid route"), false},
+		{errors.New("valhalla error 171: No suitable edges near location"), false},
+	}
+	for _, tt := range tests {
+		if got := Unavailable(tt.err); got != tt.want {
+			t.Errorf("Unavailable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package routing
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/osrm"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FakeEngine is an in-memory engine that travels in straight lines at a constant speed,
+// register it under a real engine name to exercise handlers without OSRM or Valhalla
+type FakeEngine struct {
+	EngineName string
+	// Speed in km/h
+	Speed float64
+	// Err fails every routing call, HealthErr fails the health probe
+	Err       error
+	HealthErr error
+}
+
+func NewFakeEngine(name string) *FakeEngine {
+	return &FakeEngine{EngineName: name, Speed: 50}
+}
+
+func (e *FakeEngine) Name() string {
+	return e.EngineName
+}
+
+func (e *FakeEngine) Route(coordinates string, options map[string]string) (*osrm.RouteResponse, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	points, err := parseCoordinates(coordinates)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) < 2 {
+		return nil, fmt.Errorf("invalid route")
+	}
+
+	route := osrm.Route{Geometry: osrm.Geometry{Type: "LineString", Coordinates: points}}
+	for i := 1; i < len(points); i++ {
+		distance := straightLine(points[i-1], points[i])
+		route.Legs = append(route.Legs, osrm.Leg{Distance: distance, Duration: e.duration(distance)})
+		route.Distance += distance
+	}
+	route.Duration = e.duration(route.Distance)
+	route.TrafficDuration = route.Duration
+
+	// A fake has no traffic, the snapshot keeps the aggregator from looking up traffic tiles
+	response := &osrm.RouteResponse{Code: "Ok", Routes: []osrm.Route{route}, Traffic: &osrm.TrafficSnapshot{Source: "none"}}
+	for _, point := range points {
+		response.Waypoints = append(response.Waypoints, osrm.Waypoint{Location: point})
+	}
+	return response, nil
+}
+
+func (e *FakeEngine) Matrix(sources, destinations string, options map[string]string) (*osrm.TableResponse, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	from, err := parseCoordinates(sources)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseCoordinates(destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &osrm.TableResponse{Code: "Ok"}
+	for _, source := range from {
+		var distances, durations []*float64
+		for _, destination := range to {
+			distance := straightLine(source, destination)
+			duration := e.duration(distance)
+			distances = append(distances, &distance)
+			durations = append(durations, &duration)
+		}
+		table.Distances = append(table.Distances, distances)
+		table.Durations = append(table.Durations, durations)
+	}
+	return table, nil
+}
+
+// Nearest returns every point unchanged as its own snapped location
+func (e *FakeEngine) Nearest(coordinates string, options map[string]string) ([]*osrm.Waypoint, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	points, err := parseCoordinates(coordinates)
+	if err != nil {
+		return nil, err
+	}
+
+	var waypoints []*osrm.Waypoint
+	for _, point := range points {
+		waypoints = append(waypoints, &osrm.Waypoint{Location: point})
+	}
+	return waypoints, nil
+}
+
+// Match keeps every point as matched with full confidence
+func (e *FakeEngine) Match(points []models.TracePoint, options map[string]string) (*osrm.MatchResponse, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
+
+	var coordinates []string
+	for _, point := range points {
+		coordinates = append(coordinates, fmt.Sprintf("%f,%f", point.Lon, point.Lat))
+	}
+	route, err := e.Route(strings.Join(coordinates, ";"), options)
+	if err != nil {
+		return nil, err
+	}
+
+	match := &osrm.MatchResponse{Code: "Ok", Matchings: []osrm.Matching{{Route: route.Routes[0], Confidence: 1}}}
+	for i, waypoint := range route.Waypoints {
+		match.Tracepoints = append(match.Tracepoints, &osrm.Tracepoint{Waypoint: waypoint, WaypointIndex: i})
+	}
+	return match, nil
+}
+
+func (e *FakeEngine) Health() error {
+	return e.HealthErr
+}
+
+// duration in seconds of a distance in kilometers
+func (e *FakeEngine) duration(distance float64) float64 {
+	return distance / e.Speed * 3600
+}
+
+func parseCoordinates(coordinates string) ([][]float64, error) {
+	var points [][]float64
+	for _, coordinate := range strings.Split(coordinates, ";") {
+		parts := strings.Split(coordinate, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid coordinate format: %s", coordinate)
+		}
+		lon, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid longitude: %s", parts[0])
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latitude: %s", parts[1])
+		}
+		points = append(points, []float64{lon, lat})
+	}
+	return points, nil
+}
+
+// straightLine is the great circle distance in kilometers between two [lon, lat] points
+func straightLine(from, to []float64) float64 {
+	const earthRadius = 6371.0
+	lat1, lat2 := from[1]*math.Pi/180, to[1]*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (to[0] - from[0]) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,110 @@
+package routing
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/osrm"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// OSRMEngine routes with an OSRM instance per travel profile
+type OSRMEngine struct {
+	Service *osrm.OSRMService
+}
+
+func NewOSRMEngine() *OSRMEngine {
+	return &OSRMEngine{Service: osrm.NewOSRMService()}
+}
+
+func (e *OSRMEngine) Name() string {
+	return EngineOSRM
+}
+
+func (e *OSRMEngine) Route(coordinates string, options map[string]string) (*osrm.RouteResponse, error) {
+	waypoints, err := waypointOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	requested := RequestedAvoidances(options)
+	exclude := osrmExclude(requested)
+	routeOptions := osrmWaypointParams(waypoints)
+	routeOptions["exclude"] = exclude
+	for key, value := range options {
+		routeOptions[key] = value
+	}
+
+	route, err := e.Service.GetRoute(coordinates, routeOptions)
+	if exclude != "" && (err != nil || route.Code != "Ok") {
+		// The profile has no exclude classes, fall back to the plain route
+		log.Printf("OSRM rejected exclude=%s, retrying without it", exclude)
+		exclude = ""
+		routeOptions["exclude"] = ""
+		route, err = e.Service.GetRoute(coordinates, routeOptions)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if route == nil || len(route.Routes) == 0 {
+		return nil, fmt.Errorf("invalid route")
+	}
+
+	// OSRM reports distances in meters, the rest of the API works in kilometers
+	for i := range route.Routes {
+		route.Routes[i].Distance /= 1000
+		for j := range route.Routes[i].Legs {
+			leg := &route.Routes[i].Legs[j]
+			leg.Distance /= 1000
+			for k := range leg.Steps {
+				leg.Steps[k].Distance /= 1000
+			}
+		}
+	}
+
+	route.Preferences = osrmPreferences(requested, exclude != "", RequestedTruckLimits(options))
+	// OSRM steps carry no text, write it from the maneuvers
+	osrm.LocalizeInstructions(route, options["lang"], false)
+	return route, nil
+}
+
+func (e *OSRMEngine) Matrix(sources, destinations string, options map[string]string) (*osrm.TableResponse, error) {
+	return e.Service.GetTable(sources, destinations, options)
+}
+
+// Nearest snaps the coordinates one by one, OSRM /nearest takes a single point
+func (e *OSRMEngine) Nearest(coordinates string, options map[string]string) ([]*osrm.Waypoint, error) {
+	var waypoints []*osrm.Waypoint
+	for _, coordinate := range strings.Split(coordinates, ";") {
+		waypoint, err := e.Service.GetNearest(coordinate)
+		if err != nil {
+			log.Printf("Error snapping %s: %v", coordinate, err)
+		}
+		waypoints = append(waypoints, waypoint)
+	}
+	return waypoints, nil
+}
+
+func (e *OSRMEngine) Match(points []models.TracePoint, options map[string]string) (*osrm.MatchResponse, error) {
+	var coordinates, timestamps, radiuses []string
+	for _, point := range points {
+		coordinates = append(coordinates, fmt.Sprintf("%f,%f", point.Lon, point.Lat))
+		timestamps = append(timestamps, strconv.FormatInt(point.Timestamp, 10))
+		radius := point.Radius
+		if radius == 0 {
+			radius = models.DefaultTraceRadius
+		}
+		radiuses = append(radiuses, strconv.FormatFloat(radius, 'f', -1, 64))
+	}
+
+	matchOptions := map[string]string{
+		"timestamps": strings.Join(timestamps, ";"),
+		"radiuses":   strings.Join(radiuses, ";"),
+	}
+	return e.Service.GetMatch(strings.Join(coordinates, ";"), matchOptions)
+}
+
+func (e *OSRMEngine) Health() error {
+	return e.Service.Health()
+}
@@ -0,0 +1,142 @@
+package routing
+
+import (
+	"WayPointPro/internal/models"
+	"WayPointPro/pkg/osrm"
+	"WayPointPro/pkg/valhalla"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValhallaEngine routes with Valhalla and converts the answers to the OSRM format
+type ValhallaEngine struct {
+	Service *valhalla.ValhallaService
+	// Converter only uses the Valhalla to OSRM conversions, it never calls an OSRM instance
+	Converter *osrm.OSRMService
+}
+
+func NewValhallaEngine() *ValhallaEngine {
+	return &ValhallaEngine{Service: valhalla.NewValhallaService(), Converter: &osrm.OSRMService{}}
+}
+
+func (e *ValhallaEngine) Name() string {
+	return EngineValhalla
+}
+
+func (e *ValhallaEngine) Route(coordinates string, options map[string]string) (*osrm.RouteResponse, error) {
+	locations, err := ValhallaLocations(coordinates)
+	if err != nil {
+		return nil, err
+	}
+	waypoints, err := waypointOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	applyWaypoints(locations, waypoints)
+
+	route, err := e.Service.GetRoute(locations, options)
+	if err != nil {
+		return nil, err
+	}
+	if route == nil || len(route.Trip.Legs) == 0 {
+		return nil, fmt.Errorf("invalid route")
+	}
+
+	validRoute, err := e.Converter.ConvertToOSRM(route, options["steps"] == "true")
+	if err != nil {
+		return nil, err
+	}
+	validRoute.Preferences = valhallaPreferences(RequestedAvoidances(options), validRoute)
+	// Valhalla has no narrative for some languages such as Arabic, write it from the maneuvers instead
+	if lang := options["lang"]; lang != "" && !valhalla.SupportsLanguage(lang) {
+		osrm.LocalizeInstructions(validRoute, lang, true)
+	}
+	// Valhalla times the route with its own speed data, tile traffic is not applied on top
+	validRoute.Traffic = &osrm.TrafficSnapshot{Source: "valhalla", DepartAt: options["depart_at"], ArriveBy: options["arrive_by"]}
+	return validRoute, nil
+}
+
+func (e *ValhallaEngine) Matrix(sources, destinations string, options map[string]string) (*osrm.TableResponse, error) {
+	sourceLocations, err := ValhallaLocations(sources)
+	if err != nil {
+		return nil, err
+	}
+	targetLocations, err := ValhallaLocations(destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix, err := e.Service.GetMatrix(sourceLocations, targetLocations, options)
+	if err != nil {
+		return nil, err
+	}
+	return e.Converter.ConvertMatrixToOSRM(matrix)
+}
+
+func (e *ValhallaEngine) Nearest(coordinates string, options map[string]string) ([]*osrm.Waypoint, error) {
+	locations, err := ValhallaLocations(coordinates)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := e.Service.Locate(locations, options)
+	if err != nil {
+		return nil, err
+	}
+	return e.Converter.ConvertLocateToOSRM(results), nil
+}
+
+func (e *ValhallaEngine) Match(points []models.TracePoint, options map[string]string) (*osrm.MatchResponse, error) {
+	var shape []valhalla.TracePoint
+	for _, point := range points {
+		shape = append(shape, valhalla.TracePoint{
+			Lat:    point.Lat,
+			Lon:    point.Lon,
+			Time:   point.Timestamp,
+			Radius: point.Radius,
+		})
+	}
+
+	trace, err := e.Service.TraceRoute(shape, options)
+	if err != nil {
+		return nil, err
+	}
+	attributes, err := e.Service.TraceAttributes(shape, options)
+	if err != nil {
+		return nil, err
+	}
+	return e.Converter.ConvertMatchToOSRM(trace, attributes, options["steps"] == "true")
+}
+
+func (e *ValhallaEngine) Health() error {
+	return e.Service.Status()
+}
+
+// ValhallaLocations converts a "lon,lat;lon,lat" coordinate string to Valhalla break locations
+func ValhallaLocations(coordStr string) ([]valhalla.Location, error) {
+	coords := strings.Split(coordStr, ";") // Split by ';'
+	var locations []valhalla.Location
+
+	for _, coord := range coords {
+		parts := strings.Split(coord, ",") // Split lat/lon
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid coordinate format: %s", coord)
+		}
+
+		lat, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latitude: %s", parts[1])
+		}
+
+		lon, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid longitude: %s", parts[0])
+		}
+
+		// Append to locations with "break" type (waypoints)
+		locations = append(locations, valhalla.Location{Lat: lat, Lon: lon, Type: "break"})
+	}
+
+	return locations, nil
+}
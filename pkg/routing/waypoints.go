@@ -0,0 +1,177 @@
+package routing
+
+import (
+	"WayPointPro/pkg/valhalla"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WaypointOptionsKey is the route option holding the JSON encoded per-waypoint options
+const WaypointOptionsKey = "waypoint_options"
+
+// Waypoint types, only break waypoints split the route into legs
+const (
+	WaypointBreak        = "break"
+	WaypointVia          = "via"
+	WaypointThrough      = "through"
+	WaypointBreakThrough = "break_through"
+)
+
+// Default bearing tolerance in degrees
+const defaultBearingRange = 45
+
+// WaypointOptions steer how a coordinate is snapped and passed, all fields are optional.
+// Bearing is the approach direction in degrees from north, Radius the search radius in meters,
+// SideOfStreet is same, opposite or either. Approach (curb or unrestricted) and Hint only apply to OSRM
+type WaypointOptions struct {
+	Type         string  `json:"type"`
+	Bearing      *int    `json:"bearing"`
+	BearingRange int     `json:"bearing_range"`
+	Radius       float64 `json:"radius"`
+	SideOfStreet string  `json:"side_of_street"`
+	Approach     string  `json:"approach"`
+	Hint         string  `json:"hint"`
+}
+
+// ValidateWaypoints checks there is one entry per coordinate and the route starts and ends on a break
+func ValidateWaypoints(waypoints []WaypointOptions, count int) error {
+	if len(waypoints) == 0 {
+		return nil
+	}
+	if len(waypoints) != count {
+		return fmt.Errorf("waypoints must have one entry per coordinate, got %d for %d coordinates", len(waypoints), count)
+	}
+
+	for i, waypoint := range waypoints {
+		switch waypoint.Type {
+		case "", WaypointBreak, WaypointBreakThrough:
+		case WaypointVia, WaypointThrough:
+			if i == 0 || i == count-1 {
+				return fmt.Errorf("waypoints[%d]: the first and last waypoint must be a break", i)
+			}
+		default:
+			return fmt.Errorf("waypoints[%d]: invalid type %q, expected break, via, through or break_through", i, waypoint.Type)
+		}
+		if waypoint.Bearing != nil && (*waypoint.Bearing < 0 || *waypoint.Bearing > 359) {
+			return fmt.Errorf("waypoints[%d]: bearing must be between 0 and 359", i)
+		}
+		if waypoint.BearingRange < 0 || waypoint.BearingRange > 180 {
+			return fmt.Errorf("waypoints[%d]: bearing_range must be between 0 and 180", i)
+		}
+		if waypoint.Radius < 0 || waypoint.Radius > 5000 {
+			return fmt.Errorf("waypoints[%d]: radius must be between 0 and 5000 meters", i)
+		}
+		switch waypoint.SideOfStreet {
+		case "", "same", "opposite", "either":
+		default:
+			return fmt.Errorf("waypoints[%d]: invalid side_of_street %q, expected same, opposite or either", i, waypoint.SideOfStreet)
+		}
+		switch waypoint.Approach {
+		case "", "curb", "unrestricted":
+		default:
+			return fmt.Errorf("waypoints[%d]: invalid approach %q, expected curb or unrestricted", i, waypoint.Approach)
+		}
+	}
+	return nil
+}
+
+// waypointOptions decodes the per-waypoint options of a route request
+func waypointOptions(options map[string]string) ([]WaypointOptions, error) {
+	var waypoints []WaypointOptions
+	if value := options[WaypointOptionsKey]; value != "" {
+		if err := json.Unmarshal([]byte(value), &waypoints); err != nil {
+			return nil, fmt.Errorf("invalid waypoint options: %v", err)
+		}
+	}
+	return waypoints, nil
+}
+
+// osrmWaypointParams builds the OSRM bearings, radiuses, approaches, hints and waypoints parameters
+func osrmWaypointParams(waypoints []WaypointOptions) map[string]string {
+	params := map[string]string{}
+	if len(waypoints) == 0 {
+		return params
+	}
+
+	var bearings, radiuses, approaches, hints, breaks []string
+	var hasBearing, hasRadius, hasApproach, hasHint, hasVia bool
+	for i, waypoint := range waypoints {
+		bearing := ""
+		if waypoint.Bearing != nil {
+			bearingRange := waypoint.BearingRange
+			if bearingRange == 0 {
+				bearingRange = defaultBearingRange
+			}
+			bearing = fmt.Sprintf("%d,%d", *waypoint.Bearing, bearingRange)
+			hasBearing = true
+		}
+		bearings = append(bearings, bearing)
+
+		radius := "unlimited"
+		if waypoint.Radius > 0 {
+			radius = strconv.FormatFloat(waypoint.Radius, 'f', -1, 64)
+			hasRadius = true
+		}
+		radiuses = append(radiuses, radius)
+
+		// Arriving on the same side of the street is what OSRM calls a curb approach
+		approach := waypoint.Approach
+		if approach == "" && waypoint.SideOfStreet == "same" {
+			approach = "curb"
+		}
+		hasApproach = hasApproach || approach != ""
+		approaches = append(approaches, approach)
+
+		hasHint = hasHint || waypoint.Hint != ""
+		hints = append(hints, waypoint.Hint)
+
+		if waypoint.Type == WaypointVia || waypoint.Type == WaypointThrough {
+			hasVia = true
+		} else {
+			breaks = append(breaks, strconv.Itoa(i))
+		}
+	}
+
+	if hasBearing {
+		params["bearings"] = strings.Join(bearings, ";")
+	}
+	if hasRadius {
+		params["radiuses"] = strings.Join(radiuses, ";")
+	}
+	if hasApproach {
+		params["approaches"] = strings.Join(approaches, ";")
+	}
+	if hasHint {
+		params["hints"] = strings.Join(hints, ";")
+	}
+	if hasVia {
+		params["waypoints"] = strings.Join(breaks, ";")
+	}
+	return params
+}
+
+// applyWaypoints sets the type, heading, radius and preferred side of the Valhalla locations
+func applyWaypoints(locations []valhalla.Location, waypoints []WaypointOptions) {
+	for i := range locations {
+		if i >= len(waypoints) {
+			return
+		}
+		waypoint := waypoints[i]
+
+		if waypoint.Type != "" {
+			locations[i].Type = waypoint.Type
+		}
+		if waypoint.Bearing != nil {
+			heading := *waypoint.Bearing
+			locations[i].Heading = &heading
+			locations[i].HeadingTolerance = waypoint.BearingRange
+			if locations[i].HeadingTolerance == 0 {
+				locations[i].HeadingTolerance = defaultBearingRange
+			}
+		}
+		locations[i].Radius = waypoint.Radius
+		locations[i].PreferredSide = waypoint.SideOfStreet
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"WayPointPro/internal/config"
 	"WayPointPro/internal/db"
 	"WayPointPro/internal/models"
+	"WayPointPro/pkg/eta"
+	"WayPointPro/pkg/valhalla"
 	"context"
 	"crypto/sha256"
 	"database/sql"
@@ -13,6 +15,9 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -47,11 +52,13 @@ func NewCache() *Cache {
 	return cacheInstance
 }
 
-// SaveTrafficData saves traffic data to the PostgreSQL database
-func (c *Cache) SaveTrafficData(trafficData []byte, z, x, y int) error {
-	dayOfWeek := time.Now().Weekday().String()
-	hour := time.Now().Hour()
-	minute := time.Now().Minute() / 15 * 15 // Round to the nearest 15-minute interval
+// SaveTrafficData saves the features of a tile to the PostgreSQL database in the typed form
+func (c *Cache) SaveTrafficData(features []TrafficFeature, z, x, y int) error {
+	dayOfWeek, hour, minute := TrafficBucket(time.Now())
+	trafficData, err := json.Marshal(trafficTile{Version: trafficDataVersion, Features: features})
+	if err != nil {
+		return err
+	}
 
 	query := `
 		INSERT INTO traffic_data (tile_z, tile_x, tile_y, day_of_week, hour, minute, traffic_data, updated_at)
@@ -61,23 +68,45 @@ func (c *Cache) SaveTrafficData(trafficData []byte, z, x, y int) error {
 			traffic_data = EXCLUDED.traffic_data,
 			updated_at = NOW()
 	`
-	_, err := c.DB.Exec(c.CTX, query, z, x, y, dayOfWeek, hour, minute, string(trafficData))
+	_, err = c.DB.Exec(c.CTX, query, z, x, y, dayOfWeek, hour, minute, string(trafficData))
 	return err
 }
 
-// GetTrafficData retrieves traffic data from the PostgreSQL database
-func (c *Cache) GetTrafficData(z, x, y, rangeTiles int) ([]byte, error) {
-	dayOfWeek := time.Now().Weekday().String()
-	hour := time.Now().Hour()
-	minute := time.Now().Minute() / 15 * 15 // Round to the nearest 15-minute interval
+// TrafficBucket returns the day_of_week, hour and 15-minute bucket traffic_data rows are stored under,
+// buckets are in the server time zone
+func TrafficBucket(at time.Time) (string, int, int) {
+	at = at.In(time.Local)
+	return at.Weekday().String(), at.Hour(), at.Minute() / 15 * 15 // Round to the nearest 15-minute interval
+}
+
+// TrafficBucketLabel formats a bucket as "Monday 08:15"
+func TrafficBucketLabel(at time.Time) string {
+	dayOfWeek, hour, minute := TrafficBucket(at)
+	return fmt.Sprintf("%s %02d:%02d", dayOfWeek, hour, minute)
+}
+
+// GetTrafficData retrieves the current traffic data from the PostgreSQL database, the row of the current
+// bucket only counts when it was fetched within liveTrafficMaxAge and not on a previous week
+func (c *Cache) GetTrafficData(z, x, y, rangeTiles int) ([]TrafficFeature, error) {
+	return c.getTrafficData(z, x, y, rangeTiles, time.Now(), time.Now().Add(-liveTrafficMaxAge))
+}
+
+// GetTrafficDataAt retrieves the traffic features stored for the bucket of the given time, legacy GeoJSON rows
+// are converted and invalid features skipped. A tile without rows has no features and no error
+func (c *Cache) GetTrafficDataAt(z, x, y, rangeTiles int, at time.Time) ([]TrafficFeature, error) {
+	return c.getTrafficData(z, x, y, rangeTiles, at, time.Time{})
+}
+
+func (c *Cache) getTrafficData(z, x, y, rangeTiles int, at, updatedSince time.Time) ([]TrafficFeature, error) {
+	dayOfWeek, hour, minute := TrafficBucket(at)
 
 	query := `
 		SELECT traffic_data::text
 		FROM traffic_data
 		WHERE tile_z = $1 AND tile_x BETWEEN $2 AND $3 AND tile_y BETWEEN $4 AND $5
-		AND day_of_week = $6 AND hour = $7 AND minute = $8
+		AND day_of_week = $6 AND hour = $7 AND minute = $8 AND updated_at >= $9
 	`
-	row := c.DB.QueryRow(c.CTX, query, z, x-rangeTiles, x+rangeTiles, y-rangeTiles, y+rangeTiles, dayOfWeek, hour, minute)
+	row := c.DB.QueryRow(c.CTX, query, z, x-rangeTiles, x+rangeTiles, y-rangeTiles, y+rangeTiles, dayOfWeek, hour, minute, updatedSince)
 
 	var trafficData string
 	err := row.Scan(&trafficData)
@@ -89,7 +118,14 @@ func (c *Cache) GetTrafficData(z, x, y, rangeTiles int) ([]byte, error) {
 		return nil, fmt.Errorf("failed to retrieve traffic data for tile (%d, %d, %d): %w", z, x, y, err)
 	}
 
-	return []byte(trafficData), nil
+	features, invalid, err := ParseTrafficData([]byte(trafficData))
+	if err != nil {
+		return nil, fmt.Errorf("tile (%d, %d, %d): %w", z, x, y, err)
+	}
+	if invalid > 0 {
+		log.Printf("Skipped %d invalid traffic features in tile (%d, %d, %d)", invalid, z, x, y)
+	}
+	return features, nil
 }
 
 func (c *Cache) GetFromRedis(cachedKey string) ([]byte, error) {
@@ -127,10 +163,87 @@ func (c *Cache) GeneratePlaceIDCacheKey(placeID string, lang string) string {
 	return cachedKey
 }
 
-// generateCacheKey generates a unique cache key for the request
-func (c *Cache) GenerateRouteCacheKey(coordinates string) string {
+// GenerateRouteCacheKey hashes the normalized coordinates and every route affecting option under the
+// ROUTE_CACHE_VERSION namespace, bumping the version on a deploy leaves the old entries to expire
+func (c *Cache) GenerateRouteCacheKey(coordinates string, options map[string]string) string {
+	cfg := config.LoadConfig()
+	precision, err := strconv.Atoi(cfg.RouteCachePrecision)
+	if err != nil || precision < 0 {
+		precision = defaultRouteCachePrecision
+	}
+
+	// Every option that changes the route is part of the key, in a stable order
+	parts := []string{"route", cfg.RouteCacheVersion, normalizeCoordinates(coordinates, precision)}
+	keys := make([]string, 0, len(options))
+	for key, value := range options {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, key+"="+options[key])
+	}
+	// Calibrated durations depend on the hour of the trip, traffic routes on their traffic bucket
+	at := routeTime(options)
+	parts = append(parts, "eta_bucket="+eta.Bucket(at))
+	if options["traffic"] == "true" {
+		parts = append(parts, "traffic_bucket="+TrafficBucketLabel(at))
+	}
+
+	hasher := sha256.New()
+	hasher.Write([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// Decimal places route cache keys round coordinates to when ROUTE_CACHE_PRECISION is unset, about a meter
+const defaultRouteCachePrecision = 5
+
+// normalizeCoordinates rewrites "lon,lat;lon,lat" with a fixed number of decimals so formatting
+// differences of the same trip hit the same key, unparsable values are kept as they are
+func normalizeCoordinates(coordinates string, precision int) string {
+	pairs := strings.Split(strings.TrimSpace(coordinates), ";")
+	for i, pair := range pairs {
+		values := strings.Split(pair, ",")
+		for j, value := range values {
+			number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				values[j] = strings.TrimSpace(value)
+				continue
+			}
+			values[j] = strconv.FormatFloat(number, 'f', precision, 64)
+		}
+		pairs[i] = strings.Join(values, ",")
+	}
+	return strings.Join(pairs, ";")
+}
+
+// routeTime returns the requested trip time, now for live routes
+func routeTime(options map[string]string) time.Time {
+	for _, key := range []string{"depart_at", "arrive_by"} {
+		if at, err := time.Parse(time.RFC3339, options[key]); err == nil {
+			return at
+		}
+	}
+	return time.Now()
+}
+
+// GenerateMatrixCacheKey generates a unique cache key for a matrix request, a forced engine gets its own key
+func (c *Cache) GenerateMatrixCacheKey(sources, destinations string, options map[string]string) string {
+	rawKey := ""
+	rawKey = fmt.Sprintf("matrix:%s:%s:%s", sources, destinations, options["engine"])
+	// Optional: Use hashing for consistent length and encoding safety
+	hasher := sha256.New()
+	hasher.Write([]byte(rawKey))
+	cachedKey := hex.EncodeToString(hasher.Sum(nil))
+	return cachedKey
+}
+
+// GenerateIsochroneCacheKey generates a unique cache key for an isochrone request
+func (c *Cache) GenerateIsochroneCacheKey(location string, contours []valhalla.Contour, options map[string]string) string {
 	rawKey := ""
-	rawKey = fmt.Sprintf("route:%s", coordinates)
+	rawKey = fmt.Sprintf("isochrone:%s:%v:%s:%s:%s:%s", location, contours,
+		options["costing"], options["polygons"], options["denoise"], options["generalize"])
 	// Optional: Use hashing for consistent length and encoding safety
 	hasher := sha256.New()
 	hasher.Write([]byte(rawKey))
@@ -159,6 +272,45 @@ func (c *Cache) CacheRouteResponse(cachedKey string, results models.TransformedR
 	c.RedisClient.Set(c.CTX, cachedKey, data, 3*time.Hour)
 }
 
+// CacheMatrixResponse caches the matrix response in Redis
+func (c *Cache) CacheMatrixResponse(cachedKey string, results models.TransformedMatrix) {
+	data, _ := json.Marshal(results)
+	c.RedisClient.Set(c.CTX, cachedKey, data, 3*time.Hour)
+}
+
+// CacheIsochroneResponse caches the isochrone response in Redis
+func (c *Cache) CacheIsochroneResponse(cachedKey string, results *valhalla.IsochroneResponse) {
+	data, _ := json.Marshal(results)
+	c.RedisClient.Set(c.CTX, cachedKey, data, 3*time.Hour)
+}
+
+// CacheFleetPlan stores the state of an asynchronous fleet plan in Redis
+func (c *Cache) CacheFleetPlan(plan models.FleetPlanResult) error {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	return c.RedisClient.Set(c.CTX, "fleet_plan:"+plan.ID, data, 24*time.Hour).Err()
+}
+
+// GetFleetPlan loads the state of an asynchronous fleet plan from Redis
+func (c *Cache) GetFleetPlan(id string) (*models.FleetPlanResult, error) {
+	data, err := c.GetFromRedis("fleet_plan:" + id)
+	if err != nil {
+		return nil, err
+	}
+	var plan models.FleetPlanResult
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// DeleteFleetPlan removes a fleet plan that was never queued
+func (c *Cache) DeleteFleetPlan(id string) error {
+	return c.RedisClient.Del(c.CTX, "fleet_plan:"+id).Err()
+}
+
 // storeInDatabase stores the geocoding results in the database
 func (c *Cache) SaveGecodeData(cachedKey string, results []models.GeocodingResult) {
 	for _, result := range results {
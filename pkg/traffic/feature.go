@@ -0,0 +1,171 @@
+package traffic
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Version of the typed traffic_data rows, rows without it are legacy GeoJSON FeatureCollections
+const trafficDataVersion = 2
+
+// Congestion is the traffic state of a feature in the common schema. Level is free, moderate, heavy or severe,
+// SpeedRatio the current over free flow speed and Class a road class of the optimizer speed profiles
+type Congestion struct {
+	Level      string  `json:"level"`
+	SpeedRatio float64 `json:"speed_ratio"`
+	Class      string  `json:"class"`
+	Provider   string  `json:"provider,omitempty"`
+}
+
+// TrafficFeature is a congested stretch of road, lines are [lon, lat] points
+type TrafficFeature struct {
+	Lines      [][][]float64 `json:"lines"`
+	Congestion Congestion    `json:"congestion"`
+}
+
+// trafficTile is the stored form of the features of a tile
+type trafficTile struct {
+	Version  int              `json:"version"`
+	Features []TrafficFeature `json:"features"`
+}
+
+// congestionLevels are the valid levels, legacy Mapbox rows say low for free flow
+var congestionLevels = map[string]string{
+	CongestionFree:     CongestionFree,
+	"low":              CongestionFree,
+	CongestionModerate: CongestionModerate,
+	CongestionHeavy:    CongestionHeavy,
+	CongestionSevere:   CongestionSevere,
+}
+
+// typicalSpeedRatios estimate the speed ratio of features that only carry a level
+var typicalSpeedRatios = map[string]float64{
+	CongestionFree:     1,
+	CongestionModerate: 0.7,
+	CongestionHeavy:    0.4,
+	CongestionSevere:   0.15,
+}
+
+// Validate checks the level and the lines, keeping the valid points of every line, and fills the class
+// and speed ratio when they are missing
+func (f *TrafficFeature) Validate() error {
+	level, ok := congestionLevels[f.Congestion.Level]
+	if !ok {
+		return fmt.Errorf("invalid congestion level %q", f.Congestion.Level)
+	}
+	f.Congestion.Level = level
+	if f.Congestion.Class == "" {
+		f.Congestion.Class = "unclassified"
+	}
+	if f.Congestion.SpeedRatio <= 0 || f.Congestion.SpeedRatio > 1 || math.IsNaN(f.Congestion.SpeedRatio) {
+		f.Congestion.SpeedRatio = typicalSpeedRatios[level]
+	}
+
+	var lines [][][]float64
+	for _, line := range f.Lines {
+		var points [][]float64
+		for _, point := range line {
+			if isValidPoint(point) && point[0] >= -180 && point[0] <= 180 && point[1] >= -90 && point[1] <= 90 {
+				points = append(points, point)
+			}
+		}
+		if len(points) >= 2 {
+			lines = append(lines, points)
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("feature has no line with two valid points")
+	}
+	f.Lines = lines
+	return nil
+}
+
+// ParseTrafficData reads a stored tile, typed or a legacy GeoJSON FeatureCollection. Invalid features are
+// skipped and counted, an unreadable tile is an error
+func ParseTrafficData(data []byte) ([]TrafficFeature, int, error) {
+	var tile struct {
+		Version  int               `json:"version"`
+		Features []json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(data, &tile); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse traffic data: %v", err)
+	}
+
+	var features []TrafficFeature
+	invalid := 0
+	for _, raw := range tile.Features {
+		var feature TrafficFeature
+		var err error
+		if tile.Version == trafficDataVersion {
+			err = json.Unmarshal(raw, &feature)
+		} else {
+			feature, err = parseGeoJSONFeature(raw)
+		}
+		if err == nil {
+			err = feature.Validate()
+		}
+		if err != nil {
+			invalid++
+			continue
+		}
+		features = append(features, feature)
+	}
+	return features, invalid, nil
+}
+
+// geoJSONFeature is a feature of the legacy rows and of the decoded vector tiles
+type geoJSONFeature struct {
+	Geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties struct {
+		Congestion string   `json:"congestion"`
+		Class      string   `json:"class"`
+		SpeedRatio *float64 `json:"speed_ratio"`
+		Provider   string   `json:"provider"`
+	} `json:"properties"`
+}
+
+func parseGeoJSONFeature(raw json.RawMessage) (TrafficFeature, error) {
+	var source geoJSONFeature
+	if err := json.Unmarshal(raw, &source); err != nil {
+		return TrafficFeature{}, err
+	}
+
+	feature := TrafficFeature{Congestion: Congestion{
+		Level:    source.Properties.Congestion,
+		Class:    source.Properties.Class,
+		Provider: source.Properties.Provider,
+	}}
+	if source.Properties.SpeedRatio != nil {
+		feature.Congestion.SpeedRatio = *source.Properties.SpeedRatio
+	}
+
+	switch source.Geometry.Type {
+	case "MultiLineString":
+		if err := json.Unmarshal(source.Geometry.Coordinates, &feature.Lines); err != nil {
+			return TrafficFeature{}, err
+		}
+	case "LineString":
+		var line [][]float64
+		if err := json.Unmarshal(source.Geometry.Coordinates, &line); err != nil {
+			return TrafficFeature{}, err
+		}
+		feature.Lines = [][][]float64{line}
+	default:
+		return TrafficFeature{}, fmt.Errorf("unsupported geometry %q", source.Geometry.Type)
+	}
+	return feature, nil
+}
+
+// newTrafficFeature builds a feature from the geometry of a decoded vector tile feature, only lines carry traffic
+func newTrafficFeature(geometry map[string]interface{}, congestion Congestion) (TrafficFeature, error) {
+	lines, ok := geometry["coordinates"].([][][]float64)
+	if !ok || geometry["type"] != "MultiLineString" {
+		return TrafficFeature{}, fmt.Errorf("unsupported geometry %v", geometry["type"])
+	}
+	feature := TrafficFeature{Lines: lines, Congestion: congestion}
+	return feature, feature.Validate()
+}
@@ -3,7 +3,6 @@ package traffic
 import (
 	"WayPointPro/pkg/osrm"
 	"fmt"
-	"log"
 	"math"
 	_ "math/rand"
 	_ "time"
@@ -18,7 +17,7 @@ func NewOptimizer() *Optimizer {
 	return &Optimizer{}
 }
 
-func divideTrafficData(trafficData []map[string]interface{}) ([]map[string]interface{}, []map[string]interface{}) {
+func divideTrafficData(trafficData []TrafficFeature) ([]TrafficFeature, []TrafficFeature) {
 	// Get the midpoint to divide the slice
 	mid := len(trafficData) / 2
 
@@ -30,7 +29,7 @@ func divideTrafficData(trafficData []map[string]interface{}) ([]map[string]inter
 }
 
 // AdjustRouteTime adjusts the route time based on traffic data
-func (o *Optimizer) AdjustRouteTime(route osrm.Route, trafficData []map[string]interface{}) osrm.Route {
+func (o *Optimizer) AdjustRouteTime(route osrm.Route, trafficData []TrafficFeature) osrm.Route {
 	// Step 1: Initialize total time with the original route duration
 	totalTime := route.Duration // Original travel time
 	geometry := route.Geometry.Coordinates
@@ -41,40 +40,34 @@ func (o *Optimizer) AdjustRouteTime(route osrm.Route, trafficData []map[string]i
 	// Step 3: Pre-compute congestion weights to avoid redundant calculations
 	congestionWeights := o.PrecomputeCongestionWeights()
 
-	// Step 4: Process each segment of the simplified geometry
+	// Step 4: Index the congested features so a segment is only tested against its neighbours
+	index := NewTrafficIndex(trafficData, func(feature TrafficFeature) bool {
+		return feature.Congestion.Level == CongestionSevere || feature.Congestion.Level == CongestionHeavy
+	})
+
+	// Step 5: Process each segment of the simplified geometry
 	for i := 0; i < len(simplifiedGeometry)-1; i++ {
 		segment := [2][]float64{simplifiedGeometry[i], simplifiedGeometry[i+1]}
-		// Check segment against pre-filtered traffic features
-		for _, trafficFeature := range trafficData {
-			properties := trafficFeature["properties"].(map[string]interface{})
-			congestionLevel := properties["congestion"].(string)
-
-			// Only process relevant congestion levels
-			if congestionLevel == "severe" || congestionLevel == "heavy" {
-				classType := properties["class"].(string)
-				segmentTime := o.CalculateSegmentTime(segment, classType)
-
-				// If the segment overlaps with the traffic feature, adjust time
-				if o.IsSegmentInTraffic(segment, trafficFeature) {
-					adjustedTime := congestionWeights[congestionLevel] * segmentTime
-					totalTime += adjustedTime
-					break // Process one relevant feature per segment
-				}
+		// Candidates come in feature order, the first one the segment overlaps adjusts its time
+		for _, candidate := range index.Candidates(segment) {
+			feature := index.Feature(candidate)
+			if !o.IsSegmentInTraffic(segment, feature) {
+				continue
 			}
+			segmentTime := o.CalculateSegmentTime(segment, feature.Congestion.Class)
+			totalTime += congestionWeights[feature.Congestion.Level] * segmentTime
+			break // Process one relevant feature per segment
 		}
 	}
 
-	// Step 5: Add intersection delays
+	// Step 6: Add intersection delays
 	intersectionDelay := 0.0
 	if len(route.Legs) != 0 {
 		intersectionDelay = adjustLegDurationForIntersections(route.Legs[0])
 	}
 	totalTime += intersectionDelay
 
-	// Step 6: Add a constant buffer time
-	totalTime += 60 // Add buffer time for variability
-
-	// Step 7: Update and return the adjusted route
+	// Step 7: Update and return the adjusted route, calibration is applied by the eta package
 	route.TrafficDuration = totalTime
 	return route
 }
@@ -130,6 +123,7 @@ func (o *Optimizer) PrecomputeCongestionWeights() map[string]float64 {
 	//congestionLevels := []string{"unknown", "low", "moderate", "heavy", "severe"}
 	weights := map[string]float64{
 		"unknown":  1.0,
+		"free":     1.0,
 		"low":      1.0,
 		"moderate": 1.4,
 		"heavy":    1.75,
@@ -225,12 +219,13 @@ func (o *Optimizer) PreSimplify(geometry [][]float64, gridSize float64) [][]floa
 	// Preallocate slice with the same capacity as geometry for slight optimization
 	simplified := make([][]float64, 0, len(geometry))
 
-	for _, point := range geometry {
+	for i, point := range geometry {
 		gridX := math.Round(point[0] / gridSize)
 		gridY := math.Round(point[1] / gridSize)
 		gridKey := fmt.Sprintf("%f,%f", gridX, gridY)
 
-		if !grid[gridKey] {
+		// The last point is always kept so a line that revisits a cell, such as a round trip, keeps its end
+		if !grid[gridKey] || i == len(geometry)-1 {
 			grid[gridKey] = true
 			simplified = append(simplified, point)
 		}
@@ -261,56 +256,9 @@ func (o *Optimizer) CalculateDistance(point1, point2 []float64) float64 {
 }
 
 // IsSegmentInTraffic checks if a segment intersects with traffic data
-func (o *Optimizer) IsSegmentInTraffic(segment [2][]float64, trafficFeature map[string]interface{}) bool {
-	// Safely assert trafficFeature["geometry"] as map[string]interface{}
-	geometry, ok := trafficFeature["geometry"].(map[string]interface{})
-	if !ok {
-		log.Println("Invalid geometry in traffic feature")
-		return false
-	}
-
-	// Safely assert geometry["coordinates"] as []interface{}
-	rawCoordinates, ok := geometry["coordinates"].([]interface{})
-	if !ok {
-		log.Println("Invalid coordinates in traffic feature")
-		return false
-	}
-
-	// Convert rawCoordinates ([][][]float64)
-	// Preallocate with potential max capacity for slight optimization
-	trafficGeometry := make([][][]float64, 0, len(rawCoordinates))
-
-	for _, rawLine := range rawCoordinates {
-		rawLineSlice, ok := rawLine.([]interface{})
-		if !ok {
-			log.Println("Invalid MultiLineString line")
-			continue
-		}
-
-		// Preallocate each line with length of rawLineSlice
-		lineGeometry := make([][]float64, 0, len(rawLineSlice))
-		for _, rawCoord := range rawLineSlice {
-			coordPair, ok := rawCoord.([]interface{})
-			if !ok || len(coordPair) != 2 {
-				//log.Println("Invalid coordinate pair in traffic feature")
-				continue
-			}
-
-			lat, latOk := coordPair[0].(float64)
-			lon, lonOk := coordPair[1].(float64)
-			if !latOk || !lonOk {
-				//log.Println("Invalid coordinate values in traffic feature")
-				continue
-			}
-
-			lineGeometry = append(lineGeometry, []float64{lat, lon})
-		}
-
-		trafficGeometry = append(trafficGeometry, lineGeometry)
-	}
-
+func (o *Optimizer) IsSegmentInTraffic(segment [2][]float64, trafficFeature TrafficFeature) bool {
 	// Check if the segment intersects with any traffic segment
-	for _, line := range trafficGeometry {
+	for _, line := range trafficFeature.Lines {
 		for i := 0; i < len(line)-1; i++ {
 			trafficSegment := [2][]float64{line[i], line[i+1]}
 			if o.AreSegmentsIntersecting(segment, trafficSegment) {
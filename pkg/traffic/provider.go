@@ -0,0 +1,304 @@
+package traffic
+
+import (
+	"WayPointPro/internal/config"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Congestion levels of the common traffic schema, from free flow to standstill
+const (
+	CongestionFree     = "free"
+	CongestionModerate = "moderate"
+	CongestionHeavy    = "heavy"
+	CongestionSevere   = "severe"
+)
+
+// Traffic provider names, they are also the platform of their access_tokens rows
+const (
+	ProviderMapbox = "mapbox"
+	ProviderTomTom = "tomtom"
+	ProviderHERE   = "here"
+)
+
+// TrafficProvider is a source of traffic vector tiles. Normalize maps the properties of a decoded feature
+// onto the common Congestion schema, it returns false for features without traffic
+type TrafficProvider interface {
+	Name() string
+	TileURL(z, x, y int) string
+	Authorize(request *http.Request, accessToken string)
+	ZoomRange() (int, int)
+	Normalize(properties map[string]interface{}) (Congestion, bool)
+}
+
+// ProviderZoom clamps a zoom into the range a provider serves
+func ProviderZoom(provider TrafficProvider, zoom int) int {
+	minZoom, maxZoom := provider.ZoomRange()
+	return min(max(zoom, minZoom), maxZoom)
+}
+
+// trafficProviders are the shipped providers by name
+var trafficProviders = map[string]TrafficProvider{
+	ProviderMapbox: MapboxProvider{},
+	ProviderTomTom: TomTomProvider{},
+	ProviderHERE:   HEREProvider{},
+}
+
+// defaultProviderRegion is the region of the areas outside every configured bounding box
+const defaultProviderRegion = "default"
+
+// providerRegion is a bounding box served by the provider of its name
+type providerRegion struct {
+	name                     string
+	west, south, east, north float64
+}
+
+func (r providerRegion) contains(lon, lat float64) bool {
+	return lon >= r.west && lon <= r.east && lat >= r.south && lat <= r.north
+}
+
+var (
+	regionProviders     map[string]TrafficProvider
+	providerRegions     []providerRegion
+	regionProvidersOnce sync.Once
+)
+
+// ProviderFor returns the provider configured for the region containing the centre of a bounding box.
+// TRAFFIC_PROVIDER_REGIONS defines the regions as bounding boxes such as "riyadh=46.3,24.3,47.2,25.1;jeddah=...",
+// the first region containing the centre wins. TRAFFIC_PROVIDERS maps region names to providers such as
+// "default=mapbox,riyadh=tomtom", the default region covers everything else and Mapbox is used when unset
+func ProviderFor(boundingBox map[string]float64) TrafficProvider {
+	regionProvidersOnce.Do(func() {
+		cfg := config.LoadConfig()
+		providers, err := parseTrafficProviders(cfg.TrafficProviders)
+		if err != nil {
+			log.Printf("Invalid TRAFFIC_PROVIDERS, using %s: %v", ProviderMapbox, err)
+		}
+		regions, err := parseProviderRegions(cfg.TrafficProviderRegions)
+		if err != nil {
+			log.Printf("Invalid TRAFFIC_PROVIDER_REGIONS: %v", err)
+		}
+		regionProviders, providerRegions = providers, regions
+	})
+	return providerFor(boundingBox, providerRegions, regionProviders)
+}
+
+func providerFor(boundingBox map[string]float64, regions []providerRegion, providers map[string]TrafficProvider) TrafficProvider {
+	lon, lat := (boundingBox["west"]+boundingBox["east"])/2, (boundingBox["south"]+boundingBox["north"])/2
+	for _, region := range regions {
+		if !region.contains(lon, lat) {
+			continue
+		}
+		if provider, ok := providers[region.name]; ok {
+			return provider
+		}
+	}
+	if provider, ok := providers[defaultProviderRegion]; ok {
+		return provider
+	}
+	return trafficProviders[ProviderMapbox]
+}
+
+// parseProviderRegions reads "name=west,south,east,north" boxes separated by semicolons,
+// the valid boxes are kept when one is invalid
+func parseProviderRegions(value string) ([]providerRegion, error) {
+	var regions []providerRegion
+	var err error
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, box, ok := strings.Cut(entry, "=")
+		bounds := strings.Split(box, ",")
+		if !ok || strings.TrimSpace(name) == "" || len(bounds) != 4 {
+			err = fmt.Errorf("invalid provider region %q, expected name=west,south,east,north", entry)
+			continue
+		}
+		var values [4]float64
+		var parseErr error
+		for i, bound := range bounds {
+			if values[i], parseErr = strconv.ParseFloat(strings.TrimSpace(bound), 64); parseErr != nil {
+				break
+			}
+		}
+		if parseErr != nil || values[0] >= values[2] || values[1] >= values[3] {
+			err = fmt.Errorf("invalid provider region %q, expected name=west,south,east,north", entry)
+			continue
+		}
+		regions = append(regions, providerRegion{
+			name: strings.TrimSpace(name), west: values[0], south: values[1], east: values[2], north: values[3],
+		})
+	}
+	return regions, err
+}
+
+// parseTrafficProviders reads "region=provider" pairs, the valid pairs are kept when one is invalid
+func parseTrafficProviders(value string) (map[string]TrafficProvider, error) {
+	providers := map[string]TrafficProvider{}
+	var err error
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		region, name, ok := strings.Cut(pair, "=")
+		provider, known := trafficProviders[strings.ToLower(strings.TrimSpace(name))]
+		if !ok || !known {
+			err = fmt.Errorf("invalid region provider %q, expected region=mapbox, tomtom or here", pair)
+			continue
+		}
+		providers[strings.TrimSpace(region)] = provider
+	}
+	return providers, err
+}
+
+// MapboxProvider reads the congestion layer of the Mapbox Traffic v1 tileset, it has no speeds
+// so the speed ratio is the typical one of the level
+type MapboxProvider struct{}
+
+func (MapboxProvider) Name() string { return ProviderMapbox }
+
+func (MapboxProvider) TileURL(z, x, y int) string {
+	return fmt.Sprintf("https://api.mapbox.com/v4/mapbox.mapbox-traffic-v1/%d/%d/%d.vector.pbf", z, x, y)
+}
+
+func (MapboxProvider) Authorize(request *http.Request, accessToken string) {
+	setQuery(request, "access_token", accessToken)
+}
+
+func (MapboxProvider) ZoomRange() (int, int) { return 6, 16 }
+
+func (MapboxProvider) Normalize(properties map[string]interface{}) (Congestion, bool) {
+	value, _ := properties["congestion"].(string)
+	level, ok := congestionLevels[value]
+	if !ok {
+		return Congestion{}, false
+	}
+	class, _ := properties["class"].(string)
+	return newCongestion(ProviderMapbox, level, typicalSpeedRatios[level], class), true
+}
+
+// TomTomProvider reads the relative flow tiles, traffic_level is the current over free flow speed
+type TomTomProvider struct{}
+
+// TomTom road types by the closest class of the speed profiles
+var tomtomClasses = map[string]string{
+	"Motorway":           "motorway",
+	"International road": "trunk",
+	"Major road":         "primary",
+	"Secondary road":     "secondary",
+	"Connecting road":    "tertiary",
+	"Major local road":   "tertiary",
+	"Local road":         "residential",
+	"Minor local road":   "residential",
+	"Non public road":    "service",
+	"Parking road":       "service",
+}
+
+func (TomTomProvider) Name() string { return ProviderTomTom }
+
+func (TomTomProvider) TileURL(z, x, y int) string {
+	return fmt.Sprintf("https://api.tomtom.com/traffic/map/4/tile/flow/relative/%d/%d/%d.pbf", z, x, y)
+}
+
+func (TomTomProvider) Authorize(request *http.Request, accessToken string) {
+	setQuery(request, "key", accessToken)
+}
+
+func (TomTomProvider) ZoomRange() (int, int) { return 0, 22 }
+
+func (TomTomProvider) Normalize(properties map[string]interface{}) (Congestion, bool) {
+	ratio, ok := numberProperty(properties["traffic_level"])
+	if !ok {
+		return Congestion{}, false
+	}
+	roadType, _ := properties["road_type"].(string)
+	return newCongestion(ProviderTomTom, congestionOfRatio(ratio), ratio, tomtomClasses[roadType]), true
+}
+
+// HEREProvider reads the HERE traffic flow vector tiles, speeds are compared to the free flow speed
+// and the jam factor (0 free to 10 closed) is the fallback
+type HEREProvider struct{}
+
+// HERE functional classes 1 to 5 by the closest class of the speed profiles
+var hereClasses = map[int]string{1: "motorway", 2: "trunk", 3: "primary", 4: "secondary", 5: "residential"}
+
+func (HEREProvider) Name() string { return ProviderHERE }
+
+func (HEREProvider) TileURL(z, x, y int) string {
+	return fmt.Sprintf("https://traffic.maps.hereapi.com/v3/flow/mc/%d/%d/%d/omv", z, x, y)
+}
+
+func (HEREProvider) Authorize(request *http.Request, accessToken string) {
+	setQuery(request, "apiKey", accessToken)
+}
+
+func (HEREProvider) ZoomRange() (int, int) { return 8, 17 }
+
+func (HEREProvider) Normalize(properties map[string]interface{}) (Congestion, bool) {
+	speed, hasSpeed := numberProperty(properties["speed"])
+	freeFlow, hasFreeFlow := numberProperty(properties["free_flow"])
+	jamFactor, hasJamFactor := numberProperty(properties["jam_factor"])
+
+	var ratio float64
+	switch {
+	case hasSpeed && hasFreeFlow && freeFlow > 0:
+		ratio = speed / freeFlow
+	case hasJamFactor:
+		ratio = 1 - jamFactor/10
+	default:
+		return Congestion{}, false
+	}
+	functionalClass, _ := numberProperty(properties["functional_class"])
+	return newCongestion(ProviderHERE, congestionOfRatio(ratio), ratio, hereClasses[int(functionalClass)]), true
+}
+
+// congestionOfRatio buckets a current over free flow speed ratio into a congestion level
+func congestionOfRatio(ratio float64) string {
+	switch {
+	case ratio >= 0.8:
+		return CongestionFree
+	case ratio >= 0.55:
+		return CongestionModerate
+	case ratio >= 0.3:
+		return CongestionHeavy
+	default:
+		return CongestionSevere
+	}
+}
+
+// newCongestion builds the common schema, unknown road classes fall back to unclassified
+func newCongestion(provider, level string, ratio float64, class string) Congestion {
+	if class == "" {
+		class = "unclassified"
+	}
+	return Congestion{
+		Level:      level,
+		SpeedRatio: math.Round(min(max(ratio, 0), 1)*100) / 100,
+		Class:      class,
+		Provider:   provider,
+	}
+}
+
+// numberProperty reads a decoded tile value of any numeric type
+func numberProperty(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case int64:
+		return float64(number), true
+	case uint64:
+		return float64(number), true
+	}
+	return 0, false
+}
+
+func setQuery(request *http.Request, key, value string) {
+	query := request.URL.Query()
+	query.Set(key, value)
+	request.URL.RawQuery = query.Encode()
+}
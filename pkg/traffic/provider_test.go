@@ -0,0 +1,50 @@
+package traffic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProviderRegions(t *testing.T) {
+	regions, err := parseProviderRegions("riyadh=46.3,24.3,47.2,25.1; jeddah = 38.9,21.2,39.4,21.9;broken=1,2,3;flipped=47,25,46,24")
+	if err == nil {
+		t.Errorf("expected an error for the invalid regions")
+	}
+	want := []providerRegion{
+		{name: "riyadh", west: 46.3, south: 24.3, east: 47.2, north: 25.1},
+		{name: "jeddah", west: 38.9, south: 21.2, east: 39.4, north: 21.9},
+	}
+	if !reflect.DeepEqual(regions, want) {
+		t.Errorf("regions = %+v, want %+v", regions, want)
+	}
+}
+
+func TestProviderFor(t *testing.T) {
+	regions, err := parseProviderRegions("riyadh=46.3,24.3,47.2,25.1;jeddah=38.9,21.2,39.4,21.9")
+	if err != nil {
+		t.Fatalf("parse regions: %v", err)
+	}
+	providers, err := parseTrafficProviders("default=here,riyadh=tomtom")
+	if err != nil {
+		t.Fatalf("parse providers: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		boundingBox map[string]float64
+		providers   map[string]TrafficProvider
+		want        string
+	}{
+		{"inside a region", map[string]float64{"west": 46.6, "south": 24.6, "east": 46.8, "north": 24.8}, providers, ProviderTomTom},
+		{"region without a provider uses the default", map[string]float64{"west": 39.1, "south": 21.4, "east": 39.2, "north": 21.6}, providers, ProviderHERE},
+		{"outside every region uses the default", map[string]float64{"west": 50, "south": 26, "east": 50.2, "north": 26.2}, providers, ProviderHERE},
+		{"nothing configured uses Mapbox", map[string]float64{"west": 46.6, "south": 24.6, "east": 46.8, "north": 24.8}, nil, ProviderMapbox},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := providerFor(tt.boundingBox, regions, tt.providers).Name(); got != tt.want {
+				t.Errorf("provider = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -24,27 +24,25 @@ func NewService() *Service {
 	}
 }
 
-// Choose platform and access token dynamically
-func (s *Service) choosePlatformAndToken(requiredRequests int) (string, string, error) {
+// chooseToken picks an access token of the provider with enough requests left
+func (s *Service) chooseToken(provider TrafficProvider, requiredRequests int) (string, error) {
 	query := `
-		SELECT platform, access_token, request_limit, request_count
+		SELECT access_token
 		FROM access_tokens
 		WHERE request_limit - request_count >= $1
-		AND platform = 'mapbox'
+		AND platform = $2
 		ORDER BY RANDOM()
 		LIMIT 1
 	`
 
-	row := s.Cache.DB.QueryRow(s.Cache.CTX, query, requiredRequests)
+	row := s.Cache.DB.QueryRow(s.Cache.CTX, query, requiredRequests, provider.Name())
 
-	var platform, accessToken string
-	var requestLimit, requestCount int
-
-	if err := row.Scan(&platform, &accessToken, &requestLimit, &requestCount); err != nil {
-		return "", "", fmt.Errorf("no available access tokens or failed to fetch: %v", err)
+	var accessToken string
+	if err := row.Scan(&accessToken); err != nil {
+		return "", fmt.Errorf("no available %s access tokens or failed to fetch: %v", provider.Name(), err)
 	}
 
-	return platform, accessToken, nil
+	return accessToken, nil
 }
 
 // Increment the request count for an access token
@@ -0,0 +1,90 @@
+package traffic
+
+import (
+	"math"
+	"sort"
+)
+
+// Side of a traffic index cell in degrees, about a kilometer
+const trafficIndexCellSize = 0.01
+
+type gridCell struct {
+	x, y int
+}
+
+// TrafficIndex is a uniform grid over the lines of traffic features, a segment is only tested against the
+// features sharing a cell with its bounding box
+type TrafficIndex struct {
+	cellSize float64
+	features []TrafficFeature
+	cells    map[gridCell][]int
+}
+
+// NewTrafficIndex indexes the features whose congestion passes the filter, a nil filter keeps every feature
+func NewTrafficIndex(features []TrafficFeature, keep func(feature TrafficFeature) bool) *TrafficIndex {
+	index := &TrafficIndex{
+		cellSize: trafficIndexCellSize,
+		features: features,
+		cells:    map[gridCell][]int{},
+	}
+
+	for i, feature := range features {
+		if keep != nil && !keep(feature) {
+			continue
+		}
+		for _, line := range feature.Lines {
+			for j := 0; j < len(line)-1; j++ {
+				index.eachCell(line[j], line[j+1], func(cell gridCell) {
+					cells := index.cells[cell]
+					// Consecutive segments of a line mostly share cells, skip the repeat
+					if len(cells) == 0 || cells[len(cells)-1] != i {
+						index.cells[cell] = append(cells, i)
+					}
+				})
+			}
+		}
+	}
+	return index
+}
+
+// Candidates returns the indices of the features that may intersect the segment, in feature order
+func (index *TrafficIndex) Candidates(segment [2][]float64) []int {
+	if !isValidPoint(segment[0]) || !isValidPoint(segment[1]) {
+		return nil
+	}
+
+	var candidates []int
+	index.eachCell(segment[0], segment[1], func(cell gridCell) {
+		candidates = append(candidates, index.cells[cell]...)
+	})
+	sort.Ints(candidates)
+
+	unique := candidates[:0]
+	for i, candidate := range candidates {
+		if i == 0 || candidate != candidates[i-1] {
+			unique = append(unique, candidate)
+		}
+	}
+	return unique
+}
+
+// Feature returns the indexed feature at the index
+func (index *TrafficIndex) Feature(feature int) TrafficFeature {
+	return index.features[feature]
+}
+
+// eachCell visits the cells covered by the bounding box of a segment
+func (index *TrafficIndex) eachCell(start, end []float64, visit func(cell gridCell)) {
+	if !isValidPoint(start) || !isValidPoint(end) {
+		return
+	}
+	minX := int(math.Floor(math.Min(start[0], end[0]) / index.cellSize))
+	maxX := int(math.Floor(math.Max(start[0], end[0]) / index.cellSize))
+	minY := int(math.Floor(math.Min(start[1], end[1]) / index.cellSize))
+	maxY := int(math.Floor(math.Max(start[1], end[1]) / index.cellSize))
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			visit(gridCell{x, y})
+		}
+	}
+}
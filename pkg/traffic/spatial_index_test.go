@@ -0,0 +1,146 @@
+package traffic
+
+import (
+	"WayPointPro/pkg/osrm"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// intercityFixture builds a Riyadh to Dammam route of about 400 km sampled every 100 m and a full bounding
+// box of traffic features, some of them crossing the route
+func intercityFixture() (osrm.Route, []TrafficFeature) {
+	random := rand.New(rand.NewSource(1))
+	levels := []string{CongestionFree, CongestionModerate, CongestionHeavy, CongestionSevere}
+	classes := []string{"motorway", "trunk", "primary", "secondary", "residential"}
+
+	const points = 4000
+	west, south, east, north := 46.72, 24.71, 50.10, 26.42
+	var geometry [][]float64
+	for i := 0; i < points; i++ {
+		t := float64(i) / (points - 1)
+		geometry = append(geometry, []float64{
+			west + (east-west)*t,
+			south + (north-south)*t + 0.05*math.Sin(t*20),
+		})
+	}
+	route := osrm.Route{Duration: 14400, Distance: 400000, Geometry: osrm.Geometry{Coordinates: geometry, Type: "LineString"}}
+
+	newFeature := func(start []float64, dx, dy float64) TrafficFeature {
+		line := [][]float64{start}
+		for j := 0; j < 4; j++ {
+			last := line[len(line)-1]
+			line = append(line, []float64{last[0] + dx, last[1] + dy + (random.Float64()-0.5)*0.001})
+		}
+		return TrafficFeature{
+			Lines: [][][]float64{line},
+			Congestion: Congestion{
+				Level: levels[random.Intn(len(levels))],
+				Class: classes[random.Intn(len(classes))],
+			},
+		}
+	}
+
+	var features []TrafficFeature
+	for i := 0; i < 3000; i++ {
+		start := []float64{west + random.Float64()*(east-west), south + random.Float64()*(north-south)}
+		features = append(features, newFeature(start, (random.Float64()-0.5)*0.01, (random.Float64()-0.5)*0.01))
+	}
+	// Short lines across the route so segments do meet traffic
+	for i := 0; i < 300; i++ {
+		point := geometry[random.Intn(points)]
+		features = append(features, newFeature([]float64{point[0] - 0.004, point[1] + 0.004}, 0.002, -0.002))
+	}
+	return route, features
+}
+
+func isCongested(feature TrafficFeature) bool {
+	return feature.Congestion.Level == CongestionSevere || feature.Congestion.Level == CongestionHeavy
+}
+
+// adjustRouteTimeLinear is AdjustRouteTime before the index, every segment is tested against every feature
+func adjustRouteTimeLinear(o *Optimizer, route osrm.Route, trafficData []TrafficFeature) float64 {
+	totalTime := route.Duration
+	geometry := route.Geometry.Coordinates
+	congestionWeights := o.PrecomputeCongestionWeights()
+	for i := 0; i < len(geometry)-1; i++ {
+		segment := [2][]float64{geometry[i], geometry[i+1]}
+		for _, feature := range trafficData {
+			if !isCongested(feature) || !o.IsSegmentInTraffic(segment, feature) {
+				continue
+			}
+			totalTime += congestionWeights[feature.Congestion.Level] * o.CalculateSegmentTime(segment, feature.Congestion.Class)
+			break
+		}
+	}
+	return totalTime
+}
+
+func TestTrafficIndexCandidatesMatchLinearScan(t *testing.T) {
+	route, features := intercityFixture()
+	optimizer := NewOptimizer()
+	index := NewTrafficIndex(features, isCongested)
+
+	geometry := route.Geometry.Coordinates
+	matches := 0
+	for i := 0; i < len(geometry)-1; i++ {
+		segment := [2][]float64{geometry[i], geometry[i+1]}
+
+		var indexed []int
+		for _, candidate := range index.Candidates(segment) {
+			if optimizer.IsSegmentInTraffic(segment, index.Feature(candidate)) {
+				indexed = append(indexed, candidate)
+			}
+		}
+		var linear []int
+		for j, feature := range features {
+			if isCongested(feature) && optimizer.IsSegmentInTraffic(segment, feature) {
+				linear = append(linear, j)
+			}
+		}
+
+		if len(indexed) != len(linear) {
+			t.Fatalf("segment %d: index found %v, linear scan %v", i, indexed, linear)
+		}
+		for j := range linear {
+			if indexed[j] != linear[j] {
+				t.Fatalf("segment %d: index found %v, linear scan %v", i, indexed, linear)
+			}
+		}
+		matches += len(linear)
+	}
+	if matches == 0 {
+		t.Fatal("fixture has no segment in traffic")
+	}
+
+	indexed := optimizer.AdjustRouteTime(route, features).TrafficDuration
+	if linear := adjustRouteTimeLinear(optimizer, route, features); indexed != linear {
+		t.Errorf("AdjustRouteTime = %v, linear scan = %v", indexed, linear)
+	}
+}
+
+func TestTrafficIndexSkipsInvalidPoints(t *testing.T) {
+	index := NewTrafficIndex([]TrafficFeature{{Lines: [][][]float64{{{46.7, 24.7}, {46.71, 24.71}}}}}, nil)
+	if candidates := index.Candidates([2][]float64{{math.NaN(), 24.7}, {46.71, 24.71}}); len(candidates) != 0 {
+		t.Errorf("candidates of an invalid segment = %v, want none", candidates)
+	}
+	if candidates := index.Candidates([2][]float64{{46.705, 24.705}, {46.706, 24.706}}); len(candidates) != 1 {
+		t.Errorf("candidates = %v, want the feature", candidates)
+	}
+}
+
+func BenchmarkAdjustRouteTime(b *testing.B) {
+	route, features := intercityFixture()
+	optimizer := NewOptimizer()
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			optimizer.AdjustRouteTime(route, features)
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			adjustRouteTimeLinear(optimizer, route, features)
+		}
+	})
+}
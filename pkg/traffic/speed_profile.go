@@ -0,0 +1,134 @@
+package traffic
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Live traffic rows older than this are stale, the bucket row may be from a previous week
+const liveTrafficMaxAge = 20 * time.Minute
+
+// Profiles only return segments slower than free flow, the others do not change route times
+const profileMaxSpeedRatio = 0.8
+
+// SpeedSample is the speed of one road segment in a collected tile, the segment is "lon,lat;lon,lat"
+// rounded to 5 decimals so the same stretch of road has the same key in every snapshot
+type SpeedSample struct {
+	Segment    string
+	Class      string
+	SpeedRatio float64
+	SpeedKmh   float64
+}
+
+// SpeedSamples splits the lines of the features into segments, the speed is the speed ratio of the
+// feature times the speed profile of its road class
+func SpeedSamples(features []TrafficFeature) []SpeedSample {
+	optimizer := NewOptimizer()
+	var samples []SpeedSample
+	for _, feature := range features {
+		speed := feature.Congestion.SpeedRatio * optimizer.GetSpeedForClass(feature.Congestion.Class)
+		for _, line := range feature.Lines {
+			for i := 0; i < len(line)-1; i++ {
+				samples = append(samples, SpeedSample{
+					Segment:    segmentKey(line[i], line[i+1]),
+					Class:      feature.Congestion.Class,
+					SpeedRatio: feature.Congestion.SpeedRatio,
+					SpeedKmh:   speed,
+				})
+			}
+		}
+	}
+	return samples
+}
+
+func segmentKey(start, end []float64) string {
+	return fmt.Sprintf("%.5f,%.5f;%.5f,%.5f", start[0], start[1], end[0], end[1])
+}
+
+// segmentLine reads the line back from a segment key
+func segmentLine(key string) ([][]float64, error) {
+	var line [][]float64
+	for _, pair := range strings.Split(key, ";") {
+		lon, lat, ok := strings.Cut(pair, ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid segment %q", key)
+		}
+		x, err := strconv.ParseFloat(lon, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid segment %q", key)
+		}
+		y, err := strconv.ParseFloat(lat, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid segment %q", key)
+		}
+		line = append(line, []float64{x, y})
+	}
+	return line, nil
+}
+
+// GetSpeedProfile returns the typical traffic of a tile in the bucket of a time from traffic_speed_profiles,
+// every congested segment is a feature at its median speed ratio
+func (c *Cache) GetSpeedProfile(z, x, y int, at time.Time) ([]TrafficFeature, error) {
+	dayOfWeek, hour, minute := TrafficBucket(at)
+
+	query := `
+		SELECT segment, class, median_ratio
+		FROM traffic_speed_profiles
+		WHERE tile_z = $1 AND tile_x = $2 AND tile_y = $3
+		AND day_of_week = $4 AND hour = $5 AND minute = $6 AND median_ratio < $7
+	`
+	rows, err := c.DB.Query(c.CTX, query, z, x, y, dayOfWeek, hour, minute, profileMaxSpeedRatio)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve speed profile for tile (%d, %d, %d): %w", z, x, y, err)
+	}
+	defer rows.Close()
+
+	var features []TrafficFeature
+	invalid := 0
+	for rows.Next() {
+		var segment, class string
+		var ratio float64
+		if err := rows.Scan(&segment, &class, &ratio); err != nil {
+			return nil, fmt.Errorf("failed to read speed profile for tile (%d, %d, %d): %w", z, x, y, err)
+		}
+		line, err := segmentLine(segment)
+		feature := TrafficFeature{
+			Lines:      [][][]float64{line},
+			Congestion: Congestion{Level: congestionOfRatio(ratio), SpeedRatio: ratio, Class: class, Provider: "profile"},
+		}
+		if err == nil {
+			err = feature.Validate()
+		}
+		if err != nil {
+			invalid++
+			continue
+		}
+		features = append(features, feature)
+	}
+	if invalid > 0 {
+		log.Printf("Skipped %d invalid speed profile segments in tile (%d, %d, %d)", invalid, z, x, y)
+	}
+	return features, rows.Err()
+}
+
+// FetchProfileTraffic reads the typical traffic of a bounding box for the bucket of a time
+func (s *Service) FetchProfileTraffic(boundingBox map[string]float64, zoom int, at time.Time) []TrafficFeature {
+	var trafficData []TrafficFeature
+	zoom = ProviderZoom(ProviderFor(boundingBox), zoom)
+	tileRange := s.FullGetTileRange(boundingBox, zoom)
+
+	for _, x := range tileRange["x"] {
+		for _, y := range tileRange["y"] {
+			features, err := s.Cache.GetSpeedProfile(zoom, x, y, at)
+			if err != nil {
+				log.Printf("Failed to load speed profile: %v", err)
+				continue
+			}
+			trafficData = append(trafficData, features...)
+		}
+	}
+	return trafficData
+}
@@ -0,0 +1,238 @@
+//go:build ignore
+
+// Writes the vector tile fixtures of vector_tile_test.go, run from pkg/traffic with
+//
+//	go run testdata/generate_tiles.go
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"log"
+	"math"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func main() {
+	traffic := tile(
+		layer("traffic", 4096,
+			[]string{"congestion", "class", "speed", "offset", "closed", "lanes", "ratio", "delay"},
+			[][]byte{
+				stringValue("heavy"),
+				stringValue("motorway"),
+				stringValue("low"),
+				doubleValue(42.5),
+				sintValue(-3),
+				boolValue(true),
+				uintValue(7),
+				floatValue(1.5),
+				intValue(12),
+			},
+			// A line of three points
+			feature(1, true, lineString, []uint64{0, 0, 1, 1}, []uint64{
+				command(moveTo, 1), zigzag(0), zigzag(0),
+				command(lineTo, 2), zigzag(100), zigzag(0), zigzag(0), zigzag(100),
+			}),
+			// Two lines in one feature, the second MoveTo is relative to the end of the first line
+			feature(2, true, lineString, []uint64{0, 2, 1, 1}, []uint64{
+				command(moveTo, 1), zigzag(10), zigzag(10),
+				command(lineTo, 1), zigzag(10), zigzag(0),
+				command(moveTo, 1), zigzag(10), zigzag(20),
+				command(lineTo, 1), zigzag(10), zigzag(10),
+			}),
+			// A point carrying every value type, without an id
+			feature(0, false, point, []uint64{2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8}, []uint64{
+				command(moveTo, 1), zigzag(2048), zigzag(2048),
+			}),
+			// A closed square
+			feature(4, true, polygon, nil, []uint64{
+				command(moveTo, 1), zigzag(0), zigzag(0),
+				command(lineTo, 3), zigzag(4096), zigzag(0), zigzag(0), zigzag(4096), zigzag(-4096), zigzag(0),
+				command(closePath, 1),
+			}),
+		),
+		layer("incidents", 512, []string{"kind"}, [][]byte{stringValue("closure")},
+			feature(5, true, point, []uint64{0, 0}, []uint64{
+				command(moveTo, 2), zigzag(0), zigzag(0), zigzag(512), zigzag(512),
+			}),
+		),
+	)
+	write("traffic.mvt", traffic)
+
+	var zipped bytes.Buffer
+	writer := gzip.NewWriter(&zipped)
+	writer.Write(traffic)
+	writer.Close()
+	write("traffic.mvt.gz", zipped.Bytes())
+
+	// A tile in the layout of the mapbox.mapbox-traffic-v1 tileset: one "traffic" layer of lines tagged
+	// with congestion and road class, closed roads carry closed=yes
+	mapboxKeys := []string{"congestion", "class", "closed"}
+	mapboxValues := [][]byte{
+		stringValue("low"), stringValue("moderate"), stringValue("heavy"), stringValue("severe"),
+		stringValue("motorway"), stringValue("primary"), stringValue("street"), stringValue("motorway_link"),
+		stringValue("yes"),
+	}
+	mapbox := tile(
+		layer("traffic", 4096, mapboxKeys, mapboxValues,
+			// Motorway running north to south across the tile, split where the congestion changes
+			feature(1, true, lineString, []uint64{0, 2, 1, 4}, []uint64{
+				command(moveTo, 1), zigzag(1900), zigzag(0),
+				command(lineTo, 2), zigzag(10), zigzag(1000), zigzag(5), zigzag(1000),
+			}),
+			feature(2, true, lineString, []uint64{0, 3, 1, 4}, []uint64{
+				command(moveTo, 1), zigzag(1915), zigzag(2000),
+				command(lineTo, 2), zigzag(5), zigzag(1000), zigzag(-10), zigzag(1096),
+			}),
+			feature(3, true, lineString, []uint64{0, 1, 1, 7}, []uint64{
+				command(moveTo, 1), zigzag(1905), zigzag(1500),
+				command(lineTo, 1), zigzag(200), zigzag(150),
+			}),
+			feature(4, true, lineString, []uint64{0, 0, 1, 5}, []uint64{
+				command(moveTo, 1), zigzag(0), zigzag(2300),
+				command(lineTo, 3), zigzag(1200), zigzag(20), zigzag(1500), zigzag(-40), zigzag(1396), zigzag(10),
+			}),
+			feature(5, true, lineString, []uint64{0, 3, 1, 6, 2, 8}, []uint64{
+				command(moveTo, 1), zigzag(2600), zigzag(2800),
+				command(lineTo, 1), zigzag(0), zigzag(300),
+			}),
+		),
+	)
+	var mapboxZipped bytes.Buffer
+	writer = gzip.NewWriter(&mapboxZipped)
+	writer.Write(mapbox)
+	writer.Close()
+	write("mapbox_traffic.mvt.gz", mapboxZipped.Bytes())
+
+	// LineTo announces two points but carries one
+	write("truncated_geometry.mvt", tile(
+		layer("traffic", 4096, []string{"congestion"}, [][]byte{stringValue("heavy")},
+			feature(1, true, lineString, []uint64{0, 0}, []uint64{
+				command(moveTo, 1), zigzag(0), zigzag(0),
+				command(lineTo, 2), zigzag(100), zigzag(0),
+			}),
+		),
+	))
+
+	// The value index points past the single value of the layer
+	write("tag_out_of_range.mvt", tile(
+		layer("traffic", 4096, []string{"congestion"}, [][]byte{stringValue("heavy")},
+			feature(1, true, lineString, []uint64{0, 1}, []uint64{
+				command(moveTo, 1), zigzag(0), zigzag(0),
+				command(lineTo, 1), zigzag(100), zigzag(0),
+			}),
+		),
+	))
+}
+
+const (
+	point      = 1
+	lineString = 2
+	polygon    = 3
+
+	moveTo    = 1
+	lineTo    = 2
+	closePath = 7
+)
+
+func command(id, count uint64) uint64 {
+	return id | count<<3
+}
+
+func zigzag(v int64) uint64 {
+	return protowire.EncodeZigZag(v)
+}
+
+func tile(layers ...[]byte) []byte {
+	var b []byte
+	for _, l := range layers {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, l)
+	}
+	return b
+}
+
+func layer(name string, extent uint64, keys []string, values [][]byte, features ...[]byte) []byte {
+	b := protowire.AppendTag(nil, 15, protowire.VarintType)
+	b = protowire.AppendVarint(b, 2)
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, name)
+	for _, f := range features {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, f)
+	}
+	for _, k := range keys {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, k)
+	}
+	for _, v := range values {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, v)
+	}
+	b = protowire.AppendTag(b, 5, protowire.VarintType)
+	return protowire.AppendVarint(b, extent)
+}
+
+func feature(id uint64, hasID bool, kind uint64, tags, geometry []uint64) []byte {
+	var b []byte
+	if hasID {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, id)
+	}
+	if len(tags) > 0 {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, packed(tags))
+	}
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, kind)
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	return protowire.AppendBytes(b, packed(geometry))
+}
+
+func packed(values []uint64) []byte {
+	var b []byte
+	for _, v := range values {
+		b = protowire.AppendVarint(b, v)
+	}
+	return b
+}
+
+func stringValue(s string) []byte {
+	return protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), s)
+}
+
+func floatValue(f float32) []byte {
+	return protowire.AppendFixed32(protowire.AppendTag(nil, 2, protowire.Fixed32Type), math.Float32bits(f))
+}
+
+func doubleValue(f float64) []byte {
+	return protowire.AppendFixed64(protowire.AppendTag(nil, 3, protowire.Fixed64Type), math.Float64bits(f))
+}
+
+func intValue(v int64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, 4, protowire.VarintType), uint64(v))
+}
+
+func uintValue(v uint64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, 5, protowire.VarintType), v)
+}
+
+func sintValue(v int64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, 6, protowire.VarintType), protowire.EncodeZigZag(v))
+}
+
+func boolValue(v bool) []byte {
+	b := protowire.AppendTag(nil, 7, protowire.VarintType)
+	if v {
+		return protowire.AppendVarint(b, 1)
+	}
+	return protowire.AppendVarint(b, 0)
+}
+
+func write(name string, data []byte) {
+	if err := os.WriteFile("testdata/"+name, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
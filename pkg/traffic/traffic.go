@@ -1,17 +1,47 @@
 package traffic
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"sync"
 	"time"
 )
 
-// FetchAndAnalyzeTraffic fetches traffic data and analyzes it for a bounding box
-func (s *Service) FetchAndAnalyzeTraffic(boundingBox map[string]float64, zoom int, withDelay bool) ([]map[string]interface{}, error) {
-	var trafficData []map[string]interface{}
+// DefaultTrafficZoom is the tile zoom traffic is fetched at, clamped to the zoom range of the provider
+const DefaultTrafficZoom = 11
+
+// Where the features of a tile came from
+const (
+	TileLive    = "live"
+	TileProfile = "profile"
+	TileMissing = "missing"
+)
+
+// TileSources counts the tiles of a fetch by where their features came from
+type TileSources map[string]int
+
+// Source is live or profile when every served tile agrees, mixed when both served tiles and none without tiles
+func (t TileSources) Source() string {
+	switch {
+	case t[TileLive] > 0 && t[TileProfile] > 0:
+		return "mixed"
+	case t[TileLive] > 0:
+		return TileLive
+	case t[TileProfile] > 0:
+		return TileProfile
+	}
+	return "none"
+}
+
+// FetchAndAnalyzeTraffic fetches traffic data and analyzes it for a bounding box from the provider of its region,
+// tiles that cannot be fetched fall back to their speed profile and the sources count both
+func (s *Service) FetchAndAnalyzeTraffic(boundingBox map[string]float64, zoom int, withDelay bool) ([]TrafficFeature, TileSources, error) {
+	var trafficData []TrafficFeature
+	sources := TileSources{}
+	provider := ProviderFor(boundingBox)
+	zoom = ProviderZoom(provider, zoom)
 	//tileRange := s.getTileRange(boundingBox, zoom)
 	tileRange := s.FullGetTileRange(boundingBox, zoom)
 	batches := s.batchTileRange(tileRange, 5000)
@@ -29,12 +59,12 @@ func (s *Service) FetchAndAnalyzeTraffic(boundingBox map[string]float64, zoom in
 
 		// Calculate the number of iterations for this batch
 		requiredRequests += xLen * yLen
-		// Choose platform and token dynamically
-		platform, accessTokenDB, err := s.choosePlatformAndToken(requiredRequests)
+		// Choose a token of the provider dynamically
+		accessTokenDB, err := s.chooseToken(provider, requiredRequests)
 		s.accessToken = accessTokenDB
 		if err != nil {
-			log.Printf("Failed to choose platform and token: %v", err)
-			return nil, err
+			log.Printf("Failed to choose %s token: %v", provider.Name(), err)
+			return nil, nil, err
 		}
 		for _, x := range batch["x"].([]int) {
 			for _, y := range batch["y"].([]int) {
@@ -54,18 +84,16 @@ func (s *Service) FetchAndAnalyzeTraffic(boundingBox map[string]float64, zoom in
 					}
 
 					// Fetch and process data for the tile
-					features, isRequest := s.fetchAndProcessTileData(platform, s.accessToken, zoom, x, y)
-					if features == nil {
-						return
-					}
-					if isRequest {
-						calledRequests += 1
-					}
+					features, source, isRequest := s.fetchAndProcessTileData(provider, s.accessToken, zoom, x, y)
 
 					// Append features to the result
 					mu.Lock()
+					defer mu.Unlock()
+					if isRequest {
+						calledRequests += 1
+					}
+					sources[source]++
 					trafficData = append(trafficData, features...)
-					mu.Unlock()
 				}(x, y)
 			}
 		}
@@ -75,7 +103,27 @@ func (s *Service) FetchAndAnalyzeTraffic(boundingBox map[string]float64, zoom in
 	if err := s.updateAccessTokenRequestCount(s.accessToken, calledRequests); err != nil {
 		log.Printf("Failed to update request count for access token: %v", err)
 	}
-	return trafficData, nil
+	return trafficData, sources, nil
+}
+
+// FetchStoredTraffic reads the traffic stored for the bucket of a given time over a bounding box,
+// tiles are never fetched live so departures in the future use the recorded profile of that time
+func (s *Service) FetchStoredTraffic(boundingBox map[string]float64, zoom int, at time.Time) []TrafficFeature {
+	var trafficData []TrafficFeature
+	zoom = ProviderZoom(ProviderFor(boundingBox), zoom)
+	tileRange := s.FullGetTileRange(boundingBox, zoom)
+
+	for _, x := range tileRange["x"] {
+		for _, y := range tileRange["y"] {
+			features, err := s.Cache.GetTrafficDataAt(zoom, x, y, 0, at)
+			if err != nil {
+				log.Printf("Failed to load stored traffic: %v", err)
+				continue
+			}
+			trafficData = append(trafficData, features...)
+		}
+	}
+	return trafficData
 }
 
 func (s *Service) markTileRequested(mu *sync.Mutex, requestedTiles map[string]bool, tileKey string) bool {
@@ -88,17 +136,40 @@ func (s *Service) markTileRequested(mu *sync.Mutex, requestedTiles map[string]bo
 	requestedTiles[tileKey] = true
 	return true
 }
-func (s *Service) fetchAndProcessTileData(platform, accessToken string, zoom, x, y int) ([]map[string]interface{}, bool) {
+
+// fetchAndProcessTileData returns the live features of a tile, the speed profile of the tile when it cannot be fetched,
+// and where the features came from
+func (s *Service) fetchAndProcessTileData(provider TrafficProvider, accessToken string, zoom, x, y int) ([]TrafficFeature, string, bool) {
+	features, isRequest := s.fetchTileData(provider, accessToken, zoom, x, y)
+	if features != nil {
+		return features, TileLive, isRequest
+	}
+
+	profile, err := s.Cache.GetSpeedProfile(zoom, x, y, time.Now())
+	if err != nil {
+		log.Printf("Failed to load speed profile of tile (%d, %d, %d): %v", zoom, x, y, err)
+		return nil, TileMissing, isRequest
+	}
+	log.Printf("Tile (%d, %d, %d) is timed with its speed profile", zoom, x, y)
+	return profile, TileProfile, isRequest
+}
+
+func (s *Service) fetchTileData(provider TrafficProvider, accessToken string, zoom, x, y int) ([]TrafficFeature, bool) {
 	// Check cache first
 	cachedData, _ := s.Cache.GetTrafficData(zoom, x, y, 0)
 	if cachedData != nil {
 		log.Printf("Cache hit for tile (%d, %d, %d)", zoom, x, y)
-		return s.parseTrafficData(cachedData), false
+		return cachedData, false
 	}
 
-	// Fetch data from the server
-	url := fmt.Sprintf("http://localhost:6000/decode-tile?z=%d&x=%d&y=%d&accessToken=%s&platform=%s", zoom, x, y, accessToken, platform)
-	resp, err := s.HTTPClient.Get(url)
+	// Fetch the vector tile from the provider and decode it here
+	request, err := http.NewRequest(http.MethodGet, provider.TileURL(zoom, x, y), nil)
+	if err != nil {
+		log.Printf("Failed to build tile request (%d, %d, %d): %v", zoom, x, y, err)
+		return nil, false
+	}
+	provider.Authorize(request, accessToken)
+	resp, err := s.HTTPClient.Do(request)
 	if err != nil {
 		log.Printf("Failed to fetch tile (%d, %d, %d): %v", zoom, x, y, err)
 		return nil, true
@@ -106,41 +177,46 @@ func (s *Service) fetchAndProcessTileData(platform, accessToken string, zoom, x,
 	defer resp.Body.Close()
 
 	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	tile, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Printf("Failed to read response body for tile (%d, %d, %d): %v", zoom, x, y, err)
 		return nil, true
 	}
-
-	// Cache the data
-	if err := s.Cache.SaveTrafficData(body, zoom, x, y); err != nil {
-		log.Printf("Failed to save traffic data to cache for tile (%d, %d, %d): %v", zoom, x, y, err)
-	}
-
-	// Parse and return traffic data
-	return s.parseTrafficData(body), true
-}
-func (s *Service) parseTrafficData(data []byte) []map[string]interface{} {
-	var result map[string]interface{}
-	if err := json.Unmarshal(data, &result); err != nil {
-		log.Printf("Failed to parse traffic data: %v", err)
-		return nil
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to fetch tile (%d, %d, %d) from %s: status %d", zoom, x, y, provider.Name(), resp.StatusCode)
+		return nil, true
 	}
 
-	features, ok := result["features"].([]interface{})
-	if !ok || len(features) == 0 {
-		log.Println("No features available in the response")
-		return nil
+	decoded, err := DecodeVectorTile(tile, zoom, x, y)
+	if err != nil {
+		log.Printf("Failed to decode tile (%d, %d, %d): %v", zoom, x, y, err)
+		return nil, true
 	}
-
-	var parsedFeatures []map[string]interface{}
-	for _, feature := range features {
-		featureMap, ok := feature.(map[string]interface{})
+	// Every provider is stored in the common congestion schema
+	features := []TrafficFeature{}
+	invalid := 0
+	for _, feature := range decoded {
+		properties, _ := feature["properties"].(map[string]interface{})
+		congestion, ok := provider.Normalize(properties)
 		if !ok {
-			log.Println("Invalid feature, skipping")
 			continue
 		}
-		parsedFeatures = append(parsedFeatures, featureMap)
+		geometry, _ := feature["geometry"].(map[string]interface{})
+		trafficFeature, err := newTrafficFeature(geometry, congestion)
+		if err != nil {
+			invalid++
+			continue
+		}
+		features = append(features, trafficFeature)
 	}
-	return parsedFeatures
+	if invalid > 0 {
+		log.Printf("Skipped %d invalid traffic features in tile (%d, %d, %d)", invalid, zoom, x, y)
+	}
+
+	// Cache the data
+	if err := s.Cache.SaveTrafficData(features, zoom, x, y); err != nil {
+		log.Printf("Failed to save traffic data to cache for tile (%d, %d, %d): %v", zoom, x, y, err)
+	}
+
+	return features, true
 }
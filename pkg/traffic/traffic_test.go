@@ -0,0 +1,22 @@
+package traffic
+
+import "testing"
+
+func TestTileSourcesSource(t *testing.T) {
+	tests := []struct {
+		tiles TileSources
+		want  string
+	}{
+		{TileSources{TileLive: 4}, "live"},
+		{TileSources{TileLive: 3, TileMissing: 1}, "live"},
+		{TileSources{TileProfile: 2}, "profile"},
+		{TileSources{TileLive: 3, TileProfile: 1}, "mixed"},
+		{TileSources{TileMissing: 2}, "none"},
+		{TileSources{}, "none"},
+	}
+	for _, tt := range tests {
+		if got := tt.tiles.Source(); got != tt.want {
+			t.Errorf("%v.Source() = %q, want %q", tt.tiles, got, tt.want)
+		}
+	}
+}
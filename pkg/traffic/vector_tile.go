@@ -0,0 +1,334 @@
+package traffic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the Mapbox Vector Tile 2.1 protobuf schema
+const (
+	tileLayers = 3
+
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueFloat  = 2
+	valueDouble = 3
+	valueInt    = 4
+	valueUint   = 5
+	valueSint   = 6
+	valueBool   = 7
+)
+
+// Geometry types and commands of a vector tile feature
+const (
+	geometryPoint      = 1
+	geometryLineString = 2
+	geometryPolygon    = 3
+
+	commandMoveTo    = 1
+	commandLineTo    = 2
+	commandClosePath = 7
+)
+
+// Extent of a layer that does not set one
+const defaultTileExtent = 4096
+
+// DecodeVectorTile decodes a gzipped or plain Mapbox Vector Tile into GeoJSON features with [lon, lat] coordinates.
+// Lines become MultiLineString features as the traffic sidecar produced them, the layer name is kept as a property
+func DecodeVectorTile(data []byte, z, x, y int) ([]map[string]interface{}, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip tile: %v", err)
+		}
+		defer reader.Close()
+		if data, err = io.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("invalid gzip tile: %v", err)
+		}
+	}
+
+	var features []map[string]interface{}
+	err := eachField(data, func(number protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if number != tileLayers || typ != protowire.BytesType {
+			return nil
+		}
+		layerFeatures, err := decodeLayer(value, z, x, y)
+		if err != nil {
+			return err
+		}
+		features = append(features, layerFeatures...)
+		return nil
+	})
+	return features, err
+}
+
+// rawFeature is a feature before its tags and geometry are resolved against its layer
+type rawFeature struct {
+	id       uint64
+	hasID    bool
+	tags     []uint64
+	kind     uint64
+	geometry []uint64
+}
+
+func decodeLayer(data []byte, z, x, y int) ([]map[string]interface{}, error) {
+	var name string
+	var keys []string
+	var values []interface{}
+	var raws []rawFeature
+	extent := uint64(defaultTileExtent)
+
+	err := eachField(data, func(number protowire.Number, typ protowire.Type, value []byte, scalar uint64) error {
+		switch {
+		case number == layerName && typ == protowire.BytesType:
+			name = string(value)
+		case number == layerKeys && typ == protowire.BytesType:
+			keys = append(keys, string(value))
+		case number == layerValues && typ == protowire.BytesType:
+			decoded, err := decodeValue(value)
+			if err != nil {
+				return err
+			}
+			values = append(values, decoded)
+		case number == layerExtent && typ == protowire.VarintType:
+			extent = scalar
+		case number == layerFeatures && typ == protowire.BytesType:
+			raw, err := decodeFeature(value)
+			if err != nil {
+				return err
+			}
+			raws = append(raws, raw)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("layer %q: %v", name, err)
+	}
+	if extent == 0 {
+		return nil, fmt.Errorf("layer %q: extent is 0", name)
+	}
+
+	projection := tileProjection{z: z, x: x, y: y, extent: float64(extent)}
+	var features []map[string]interface{}
+	for _, raw := range raws {
+		properties := map[string]interface{}{"layer": name}
+		for i := 0; i+1 < len(raw.tags); i += 2 {
+			key, value := raw.tags[i], raw.tags[i+1]
+			if key >= uint64(len(keys)) || value >= uint64(len(values)) {
+				return nil, fmt.Errorf("layer %q: feature tag out of range", name)
+			}
+			properties[keys[key]] = values[value]
+		}
+
+		geometry, err := decodeGeometry(raw.kind, raw.geometry, projection)
+		if err != nil {
+			return nil, fmt.Errorf("layer %q: %v", name, err)
+		}
+		if geometry == nil {
+			continue
+		}
+
+		feature := map[string]interface{}{
+			"type":       "Feature",
+			"geometry":   geometry,
+			"properties": properties,
+		}
+		if raw.hasID {
+			feature["id"] = raw.id
+		}
+		features = append(features, feature)
+	}
+	return features, nil
+}
+
+func decodeFeature(data []byte) (rawFeature, error) {
+	var raw rawFeature
+	err := eachField(data, func(number protowire.Number, typ protowire.Type, value []byte, scalar uint64) error {
+		switch number {
+		case featureID:
+			raw.id, raw.hasID = scalar, true
+		case featureType:
+			raw.kind = scalar
+		case featureTags:
+			packed, err := packedVarints(typ, value, scalar)
+			if err != nil {
+				return err
+			}
+			raw.tags = append(raw.tags, packed...)
+		case featureGeometry:
+			packed, err := packedVarints(typ, value, scalar)
+			if err != nil {
+				return err
+			}
+			raw.geometry = append(raw.geometry, packed...)
+		}
+		return nil
+	})
+	return raw, err
+}
+
+func decodeValue(data []byte) (interface{}, error) {
+	var decoded interface{}
+	err := eachField(data, func(number protowire.Number, typ protowire.Type, value []byte, scalar uint64) error {
+		switch number {
+		case valueString:
+			decoded = string(value)
+		case valueFloat:
+			decoded = float64(math.Float32frombits(uint32(scalar)))
+		case valueDouble:
+			decoded = math.Float64frombits(scalar)
+		case valueInt:
+			decoded = int64(scalar)
+		case valueUint:
+			decoded = scalar
+		case valueSint:
+			decoded = protowire.DecodeZigZag(scalar)
+		case valueBool:
+			decoded = scalar != 0
+		}
+		return nil
+	})
+	return decoded, err
+}
+
+// tileProjection converts tile pixel coordinates to [lon, lat] in Web Mercator
+type tileProjection struct {
+	z, x, y int
+	extent  float64
+}
+
+func (p tileProjection) lonLat(px, py int64) []float64 {
+	size := p.extent * math.Exp2(float64(p.z))
+	worldX := (float64(p.x)*p.extent + float64(px)) / size
+	worldY := (float64(p.y)*p.extent + float64(py)) / size
+
+	lon := worldX*360 - 180
+	lat := math.Atan(math.Sinh(math.Pi*(1-2*worldY))) * 180 / math.Pi
+	return []float64{math.Round(lon*1e7) / 1e7, math.Round(lat*1e7) / 1e7}
+}
+
+// decodeGeometry runs the MoveTo, LineTo and ClosePath commands of a feature into a GeoJSON geometry,
+// coordinates are zigzag deltas from the previous cursor position
+func decodeGeometry(kind uint64, commands []uint64, projection tileProjection) (map[string]interface{}, error) {
+	var parts [][][]float64
+	var cursorX, cursorY int64
+
+	for i := 0; i < len(commands); {
+		command, count := commands[i]&0x7, int(commands[i]>>3)
+		i++
+		switch command {
+		case commandMoveTo, commandLineTo:
+			if i+2*count > len(commands) {
+				return nil, fmt.Errorf("geometry command runs past the end")
+			}
+			for j := 0; j < count; j++ {
+				cursorX += protowire.DecodeZigZag(commands[i])
+				cursorY += protowire.DecodeZigZag(commands[i+1])
+				i += 2
+				point := projection.lonLat(cursorX, cursorY)
+				if command == commandMoveTo || len(parts) == 0 {
+					parts = append(parts, [][]float64{point})
+				} else {
+					parts[len(parts)-1] = append(parts[len(parts)-1], point)
+				}
+			}
+		case commandClosePath:
+			if len(parts) > 0 && len(parts[len(parts)-1]) > 0 {
+				ring := parts[len(parts)-1]
+				parts[len(parts)-1] = append(ring, ring[0])
+			}
+		default:
+			return nil, fmt.Errorf("unknown geometry command %d", command)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	switch kind {
+	case geometryPoint:
+		var points [][]float64
+		for _, part := range parts {
+			points = append(points, part...)
+		}
+		if len(points) == 1 {
+			return map[string]interface{}{"type": "Point", "coordinates": points[0]}, nil
+		}
+		return map[string]interface{}{"type": "MultiPoint", "coordinates": points}, nil
+	case geometryLineString:
+		return map[string]interface{}{"type": "MultiLineString", "coordinates": parts}, nil
+	case geometryPolygon:
+		return map[string]interface{}{"type": "Polygon", "coordinates": parts}, nil
+	}
+	return nil, nil
+}
+
+// eachField walks the fields of a protobuf message, passing length delimited contents as value
+// and varint or fixed width numbers as scalar
+func eachField(data []byte, visit func(number protowire.Number, typ protowire.Type, value []byte, scalar uint64) error) error {
+	for len(data) > 0 {
+		number, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var value []byte
+		var scalar uint64
+		switch typ {
+		case protowire.VarintType:
+			scalar, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed32Type:
+			var fixed uint32
+			fixed, n = protowire.ConsumeFixed32(data)
+			scalar = uint64(fixed)
+		case protowire.Fixed64Type:
+			scalar, n = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(number, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if err := visit(number, typ, value, scalar); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packedVarints reads a packed repeated uint32 field, or a single unpacked element
+func packedVarints(typ protowire.Type, value []byte, scalar uint64) ([]uint64, error) {
+	if typ == protowire.VarintType {
+		return []uint64{scalar}, nil
+	}
+	var values []uint64
+	for len(value) > 0 {
+		v, n := protowire.ConsumeVarint(value)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		values = append(values, v)
+		value = value[n:]
+	}
+	return values, nil
+}
@@ -0,0 +1,243 @@
+package traffic
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Tile of the fixtures, over Riyadh
+const (
+	fixtureZ = 14
+	fixtureX = 10317
+	fixtureY = 7031
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return data
+}
+
+// pixel returns the [lon, lat] of a pixel of the fixture tile with the slippy map tile formulas
+func pixel(extent, px, py float64) []float64 {
+	n := math.Exp2(fixtureZ)
+	lon := (fixtureX+px/extent)/n*360 - 180
+	lat := math.Atan(math.Sinh(math.Pi*(1-2*(fixtureY+py/extent)/n))) * 180 / math.Pi
+	return []float64{lon, lat}
+}
+
+func decodeFixture(t *testing.T, name string) []map[string]interface{} {
+	t.Helper()
+	features, err := DecodeVectorTile(readFixture(t, name), fixtureZ, fixtureX, fixtureY)
+	if err != nil {
+		t.Fatalf("decode %s: %v", name, err)
+	}
+	return features
+}
+
+func assertCoordinates(t *testing.T, got interface{}, want interface{}) {
+	t.Helper()
+	var compare func(got, want reflect.Value) bool
+	compare = func(got, want reflect.Value) bool {
+		if want.Kind() == reflect.Float64 {
+			return got.Kind() == reflect.Float64 && math.Abs(got.Float()-want.Float()) < 1e-6
+		}
+		if got.Kind() != reflect.Slice || got.Len() != want.Len() {
+			return false
+		}
+		for i := 0; i < want.Len(); i++ {
+			if !compare(got.Index(i), want.Index(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	if !compare(reflect.ValueOf(got), reflect.ValueOf(want)) {
+		t.Errorf("coordinates = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeVectorTile(t *testing.T) {
+	features := decodeFixture(t, "traffic.mvt")
+	if len(features) != 5 {
+		t.Fatalf("decoded %d features, want 5", len(features))
+	}
+
+	tests := []struct {
+		name        string
+		id          interface{}
+		kind        string
+		coordinates interface{}
+		properties  map[string]interface{}
+	}{
+		{
+			name: "line",
+			id:   uint64(1),
+			kind: "MultiLineString",
+			coordinates: [][][]float64{{
+				pixel(4096, 0, 0), pixel(4096, 100, 0), pixel(4096, 100, 100),
+			}},
+			properties: map[string]interface{}{"layer": "traffic", "congestion": "heavy", "class": "motorway"},
+		},
+		{
+			name: "two lines, MoveTo continues from the last cursor",
+			id:   uint64(2),
+			kind: "MultiLineString",
+			coordinates: [][][]float64{
+				{pixel(4096, 10, 10), pixel(4096, 20, 10)},
+				{pixel(4096, 30, 30), pixel(4096, 40, 40)},
+			},
+			properties: map[string]interface{}{"layer": "traffic", "congestion": "low", "class": "motorway"},
+		},
+		{
+			name:        "point with every value type",
+			kind:        "Point",
+			coordinates: pixel(4096, 2048, 2048),
+			properties: map[string]interface{}{
+				"layer":  "traffic",
+				"speed":  42.5,
+				"offset": int64(-3),
+				"closed": true,
+				"lanes":  uint64(7),
+				"ratio":  1.5,
+				"delay":  int64(12),
+			},
+		},
+		{
+			name: "ClosePath closes the ring",
+			id:   uint64(4),
+			kind: "Polygon",
+			coordinates: [][][]float64{{
+				pixel(4096, 0, 0), pixel(4096, 4096, 0), pixel(4096, 4096, 4096), pixel(4096, 0, 4096), pixel(4096, 0, 0),
+			}},
+			properties: map[string]interface{}{"layer": "traffic"},
+		},
+		{
+			name:        "second layer with its own extent",
+			id:          uint64(5),
+			kind:        "MultiPoint",
+			coordinates: [][]float64{pixel(512, 0, 0), pixel(512, 512, 512)},
+			properties:  map[string]interface{}{"layer": "incidents", "kind": "closure"},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feature := features[i]
+			if id, ok := feature["id"]; tt.id == nil && ok {
+				t.Errorf("id = %v, want none", id)
+			} else if tt.id != nil && id != tt.id {
+				t.Errorf("id = %v, want %v", id, tt.id)
+			}
+
+			geometry := feature["geometry"].(map[string]interface{})
+			if geometry["type"] != tt.kind {
+				t.Errorf("geometry type = %v, want %s", geometry["type"], tt.kind)
+			}
+			assertCoordinates(t, geometry["coordinates"], tt.coordinates)
+
+			if properties := feature["properties"]; !reflect.DeepEqual(properties, tt.properties) {
+				t.Errorf("properties = %v, want %v", properties, tt.properties)
+			}
+		})
+	}
+}
+
+func TestDecodeVectorTileGzip(t *testing.T) {
+	plain := decodeFixture(t, "traffic.mvt")
+	zipped := decodeFixture(t, "traffic.mvt.gz")
+	if !reflect.DeepEqual(plain, zipped) {
+		t.Errorf("gzipped tile decoded differently from the plain tile")
+	}
+}
+
+func TestDecodeVectorTileErrors(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		{"truncated_geometry.mvt", "geometry command runs past the end"},
+		{"tag_out_of_range.mvt", "feature tag out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			_, err := DecodeVectorTile(readFixture(t, tt.fixture), fixtureZ, fixtureX, fixtureY)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A gzip header without a valid stream
+	if _, err := DecodeVectorTile([]byte{0x1f, 0x8b, 0x00}, fixtureZ, fixtureX, fixtureY); err == nil {
+		t.Errorf("expected an error for a broken gzip tile")
+	}
+}
+
+func TestTileProjection(t *testing.T) {
+	tests := []struct {
+		name       string
+		projection tileProjection
+		px, py     int64
+		want       []float64
+	}{
+		{"world north west corner", tileProjection{z: 0, x: 0, y: 0, extent: 4096}, 0, 0, []float64{-180, 85.0511288}},
+		{"world centre", tileProjection{z: 0, x: 0, y: 0, extent: 4096}, 2048, 2048, []float64{0, 0}},
+		{"centre of an eastern tile", tileProjection{z: 1, x: 1, y: 0, extent: 4096}, 2048, 2048, []float64{90, 66.5132604}},
+		{"south east corner", tileProjection{z: 1, x: 1, y: 1, extent: 4096}, 4096, 4096, []float64{180, -85.0511288}},
+		{"buffer pixel outside the tile", tileProjection{z: 1, x: 0, y: 0, extent: 4096}, -4096, 0, []float64{-360, 85.0511288}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertCoordinates(t, tt.projection.lonLat(tt.px, tt.py), tt.want)
+		})
+	}
+}
+
+func TestDecodeVectorTileMapboxTraffic(t *testing.T) {
+	features := decodeFixture(t, "mapbox_traffic.mvt.gz")
+	if len(features) != 5 {
+		t.Fatalf("decoded %d features, want 5", len(features))
+	}
+
+	layers := map[interface{}]int{}
+	classes := map[interface{}]int{}
+	congestion := map[interface{}]int{}
+	closed := 0
+	for _, feature := range features {
+		properties := feature["properties"].(map[string]interface{})
+		layers[properties["layer"]]++
+		classes[properties["class"]]++
+		congestion[properties["congestion"]]++
+		if properties["closed"] == "yes" {
+			closed++
+		}
+		if kind := feature["geometry"].(map[string]interface{})["type"]; kind != "MultiLineString" {
+			t.Errorf("feature %v geometry = %v, want MultiLineString", feature["id"], kind)
+		}
+	}
+
+	if want := map[interface{}]int{"traffic": 5}; !reflect.DeepEqual(layers, want) {
+		t.Errorf("layers = %v, want %v", layers, want)
+	}
+	if want := map[interface{}]int{"motorway": 2, "motorway_link": 1, "primary": 1, "street": 1}; !reflect.DeepEqual(classes, want) {
+		t.Errorf("classes = %v, want %v", classes, want)
+	}
+	if want := map[interface{}]int{"low": 1, "moderate": 1, "heavy": 1, "severe": 2}; !reflect.DeepEqual(congestion, want) {
+		t.Errorf("congestion = %v, want %v", congestion, want)
+	}
+	if closed != 1 {
+		t.Errorf("%d closed roads, want 1", closed)
+	}
+
+	// The motorway crosses the tile from its north to its south edge
+	first := features[0]["geometry"].(map[string]interface{})["coordinates"].([][][]float64)[0]
+	assertCoordinates(t, first[0], pixel(4096, 1900, 0))
+	assertCoordinates(t, first[len(first)-1], pixel(4096, 1915, 2000))
+}
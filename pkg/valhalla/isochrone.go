@@ -0,0 +1,76 @@
+package valhalla
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// Request Struct for Valhalla isochrone API
+type IsochroneRequest struct {
+	Locations  []Location `json:"locations"`
+	Costing    string     `json:"costing"`
+	Contours   []Contour  `json:"contours"`
+	Polygons   bool       `json:"polygons"`
+	Denoise    *float64   `json:"denoise,omitempty"`
+	Generalize *float64   `json:"generalize,omitempty"`
+}
+
+// Contour is one isochrone level, Time in minutes or Distance in kilometers
+type Contour struct {
+	Time     float64 `json:"time,omitempty"`
+	Distance float64 `json:"distance,omitempty"`
+}
+
+// Response Struct for Valhalla isochrone API (GeoJSON FeatureCollection)
+type IsochroneResponse struct {
+	Type     string             `json:"type"`
+	Features []IsochroneFeature `json:"features"`
+}
+
+type IsochroneFeature struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+// Function to request isochrone contours from Valhalla API
+func (s *ValhallaService) GetIsochrone(location Location, contours []Contour, options map[string]string) (*IsochroneResponse, error) {
+	url := fmt.Sprintf("%s/isochrone", s.BaseURL)
+
+	requestData := IsochroneRequest{
+		Locations: []Location{location},
+		Contours:  contours,
+	}
+
+	for key, value := range options {
+		if key == "costing" {
+			requestData.Costing = value
+		}
+		if key == "polygons" {
+			requestData.Polygons = value == "true"
+		}
+		if key == "denoise" && value != "" {
+			if denoise, err := strconv.ParseFloat(value, 64); err == nil {
+				requestData.Denoise = &denoise
+			}
+		}
+		if key == "generalize" && value != "" {
+			if generalize, err := strconv.ParseFloat(value, 64); err == nil {
+				requestData.Generalize = &generalize
+			}
+		}
+	}
+
+	var isochroneResponse IsochroneResponse
+	if err := s.post(url, requestData, &isochroneResponse); err != nil {
+		log.Printf("Error decoding isochrone response: %v", err)
+		return nil, err
+	}
+
+	return &isochroneResponse, nil
+}
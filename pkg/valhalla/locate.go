@@ -0,0 +1,60 @@
+package valhalla
+
+import (
+	"fmt"
+	"log"
+)
+
+// Request Struct for Valhalla locate API
+type LocateRequest struct {
+	Locations []Location `json:"locations"`
+	Costing   string     `json:"costing"`
+	Verbose   bool       `json:"verbose"`
+}
+
+// LocateResult is the verbose locate answer for one input location
+type LocateResult struct {
+	InputLat float64      `json:"input_lat"`
+	InputLon float64      `json:"input_lon"`
+	Edges    []LocateEdge `json:"edges"`
+}
+
+type LocateEdge struct {
+	CorrelatedLat float64 `json:"correlated_lat"`
+	CorrelatedLon float64 `json:"correlated_lon"`
+	SideOfStreet  string  `json:"side_of_street"`
+	PercentAlong  float64 `json:"percent_along"`
+	EdgeInfo      struct {
+		Names []string `json:"names"`
+	} `json:"edge_info"`
+	Edge struct {
+		Classification struct {
+			Classification string `json:"classification"`
+			Use            string `json:"use"`
+		} `json:"classification"`
+	} `json:"edge"`
+}
+
+// Function to snap locations to the road network with Valhalla locate API
+func (s *ValhallaService) Locate(locations []Location, options map[string]string) ([]LocateResult, error) {
+	url := fmt.Sprintf("%s/locate", s.BaseURL)
+
+	requestData := LocateRequest{
+		Locations: locations,
+		Verbose:   true,
+	}
+
+	for key, value := range options {
+		if key == "costing" {
+			requestData.Costing = value
+		}
+	}
+
+	var results []LocateResult
+	if err := s.post(url, requestData, &results); err != nil {
+		log.Printf("Error decoding locate response: %v", err)
+		return nil, err
+	}
+
+	return results, nil
+}
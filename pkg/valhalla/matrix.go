@@ -0,0 +1,61 @@
+package valhalla
+
+import (
+	"fmt"
+	"log"
+)
+
+// Request Struct for Valhalla sources_to_targets API
+type MatrixRequest struct {
+	Sources []Location `json:"sources"`
+	Targets []Location `json:"targets"`
+	Costing string     `json:"costing"`
+	Units   string     `json:"units,omitempty"`
+}
+
+// Response Struct for Valhalla sources_to_targets API
+type MatrixResponse struct {
+	Sources          []Location     `json:"-"`
+	Targets          []Location     `json:"-"`
+	SourcesToTargets [][]MatrixCell `json:"sources_to_targets"`
+	Units            string         `json:"units"`
+	Error            string         `json:"error,omitempty"`
+}
+
+// MatrixCell holds one source/target pair, Time and Distance are nil when no route was found
+type MatrixCell struct {
+	Distance  *float64 `json:"distance"`
+	Time      *float64 `json:"time"`
+	FromIndex int      `json:"from_index"`
+	ToIndex   int      `json:"to_index"`
+}
+
+// Function to request a many-to-many matrix from Valhalla API
+func (s *ValhallaService) GetMatrix(sources, targets []Location, options map[string]string) (*MatrixResponse, error) {
+	url := fmt.Sprintf("%s/sources_to_targets", s.BaseURL)
+
+	requestData := MatrixRequest{
+		Sources: sources,
+		Targets: targets,
+		Units:   "kilometers",
+	}
+
+	for key, value := range options {
+		if key == "costing" {
+			requestData.Costing = value
+		}
+	}
+
+	var matrixResponse MatrixResponse
+	if err := s.post(url, requestData, &matrixResponse); err != nil {
+		log.Printf("Error decoding matrix response: %v", err)
+		return nil, err
+	}
+	if matrixResponse.Error != "" {
+		return nil, fmt.Errorf("valhalla matrix error: %s", matrixResponse.Error)
+	}
+
+	matrixResponse.Sources = sources
+	matrixResponse.Targets = targets
+	return &matrixResponse, nil
+}
@@ -0,0 +1,103 @@
+package valhalla
+
+import (
+	"fmt"
+	"log"
+)
+
+// TracePoint is one GPS sample of a trace, Time is a unix timestamp
+type TracePoint struct {
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Time   int64   `json:"time,omitempty"`
+	Radius float64 `json:"radius,omitempty"`
+}
+
+// Request Struct for Valhalla trace_route and trace_attributes APIs
+type TraceRequest struct {
+	Shape             []TracePoint      `json:"shape"`
+	Costing           string            `json:"costing"`
+	ShapeMatch        string            `json:"shape_match"`
+	UseTimestamps     bool              `json:"use_timestamps,omitempty"`
+	DirectionsOptions DirectionsOptions `json:"directions_options,omitempty"`
+	Filters           *TraceFilters     `json:"filters,omitempty"`
+}
+
+type TraceFilters struct {
+	Attributes []string `json:"attributes"`
+	Action     string   `json:"action"`
+}
+
+// Response Struct for Valhalla trace_attributes API
+type TraceAttributesResponse struct {
+	ConfidenceScore float64        `json:"confidence_score"`
+	MatchedPoints   []MatchedPoint `json:"matched_points"`
+}
+
+// MatchedPoint is the snapped position of a trace point, Type is matched, interpolated or unmatched
+type MatchedPoint struct {
+	Lat                    float64 `json:"lat"`
+	Lon                    float64 `json:"lon"`
+	Type                   string  `json:"type"`
+	EdgeIndex              int     `json:"edge_index"`
+	DistanceFromTracePoint float64 `json:"distance_from_trace_point"`
+}
+
+// Function to snap a GPS trace to the road network with Valhalla trace_route API
+func (s *ValhallaService) TraceRoute(shape []TracePoint, options map[string]string) (*RouteResponse, error) {
+	url := fmt.Sprintf("%s/trace_route", s.BaseURL)
+
+	requestData := s.traceRequest(shape, options)
+
+	var routeResponse RouteResponse
+	if err := s.post(url, requestData, &routeResponse); err != nil {
+		log.Printf("Error decoding trace route response: %v", err)
+		return nil, err
+	}
+
+	return &routeResponse, nil
+}
+
+// Function to fetch per point match details with Valhalla trace_attributes API
+func (s *ValhallaService) TraceAttributes(shape []TracePoint, options map[string]string) (*TraceAttributesResponse, error) {
+	url := fmt.Sprintf("%s/trace_attributes", s.BaseURL)
+
+	requestData := s.traceRequest(shape, options)
+	requestData.Filters = &TraceFilters{
+		Attributes: []string{
+			"matched.point",
+			"matched.type",
+			"matched.edge_index",
+			"matched.distance_from_trace_point",
+			"confidence_score",
+		},
+		Action: "include",
+	}
+
+	var attributesResponse TraceAttributesResponse
+	if err := s.post(url, requestData, &attributesResponse); err != nil {
+		log.Printf("Error decoding trace attributes response: %v", err)
+		return nil, err
+	}
+
+	return &attributesResponse, nil
+}
+
+func (s *ValhallaService) traceRequest(shape []TracePoint, options map[string]string) TraceRequest {
+	requestData := TraceRequest{
+		Shape:         shape,
+		ShapeMatch:    "map_snap",
+		UseTimestamps: len(shape) > 0 && shape[0].Time != 0,
+		DirectionsOptions: DirectionsOptions{
+			Units: "kilometers",
+		},
+	}
+
+	for key, value := range options {
+		if key == "costing" {
+			requestData.Costing = value
+		}
+	}
+
+	return requestData
+}
@@ -8,6 +8,8 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type ValhallaService struct {
@@ -16,11 +18,19 @@ type ValhallaService struct {
 
 // Request Struct for Valhalla API
 type RouteRequest struct {
-	Locations []Location `json:"locations"`
-	Costing   string     `json:"costing"`
-	//CostingOptions    CostingOptions    `json:"costing_options,omitempty"`
+	Locations         []Location        `json:"locations"`
+	Costing           string            `json:"costing"`
+	CostingOptions    *CostingOptions   `json:"costing_options,omitempty"`
+	ExcludePolygons   [][][]float64     `json:"exclude_polygons,omitempty"`
 	DirectionsOptions DirectionsOptions `json:"directions_options,omitempty"`
 	Alternates        int               `json:"alternates,omitempty"`
+	DateTime          *DateTime         `json:"date_time,omitempty"`
+}
+
+// DateTime is a departure (type 1) or arrival (type 2) time, Value is local time at the location
+type DateTime struct {
+	Type  int    `json:"type"`
+	Value string `json:"value"`
 }
 
 // Response Struct for Valhalla API
@@ -36,10 +46,15 @@ type Trip struct {
 }
 
 type Location struct {
-	Lat          float64 `json:"lat"`
-	Lon          float64 `json:"lon"`
-	Type         string  `json:"type,omitempty"`
-	SideOfStreet string  `json:"side_of_street,omitempty"`
+	Lat              float64 `json:"lat"`
+	Lon              float64 `json:"lon"`
+	Type             string  `json:"type,omitempty"`
+	Heading          *int    `json:"heading,omitempty"`
+	HeadingTolerance int     `json:"heading_tolerance,omitempty"`
+	Radius           float64 `json:"radius,omitempty"`
+	PreferredSide    string  `json:"preferred_side,omitempty"`
+	SideOfStreet     string  `json:"side_of_street,omitempty"`
+	OriginalIndex    int     `json:"original_index,omitempty"`
 }
 
 type Leg struct {
@@ -69,7 +84,9 @@ type Maneuver struct {
 	VerbalPreTransitionInstruction      string   `json:"verbal_pre_transition_instruction"`
 	VerbalPostTransitionInstruction     string   `json:"verbal_post_transition_instruction"`
 	StreetNames                         []string `json:"street_names"`
+	BearingBefore                       int      `json:"bearing_before"`
 	BearingAfter                        int      `json:"bearing_after"`
+	RoundaboutExitCount                 int      `json:"roundabout_exit_count,omitempty"`
 	Time                                float64  `json:"time"`
 	Length                              float64  `json:"length"`
 	Cost                                float64  `json:"cost"`
@@ -80,15 +97,61 @@ type Maneuver struct {
 }
 
 type CostingOptions struct {
-	Auto struct {
-		UseHighways float64 `json:"use_highways,omitempty"`
-		UseTolls    float64 `json:"use_tolls,omitempty"`
-		UseFerry    float64 `json:"use_ferry,omitempty"`
-	} `json:"auto,omitempty"`
+	Auto       *CostingModelOptions `json:"auto,omitempty"`
+	Truck      *CostingModelOptions `json:"truck,omitempty"`
+	Motorcycle *CostingModelOptions `json:"motorcycle,omitempty"`
+	Bicycle    *CostingModelOptions `json:"bicycle,omitempty"`
+	Pedestrian *CostingModelOptions `json:"pedestrian,omitempty"`
+}
+
+// CostingModelOptions are the options of one costing model, nil values keep Valhalla defaults.
+// Truck dimensions are in meters and weights in metric tons
+type CostingModelOptions struct {
+	UseHighways *float64 `json:"use_highways,omitempty"`
+	UseTolls    *float64 `json:"use_tolls,omitempty"`
+	UseFerry    *float64 `json:"use_ferry,omitempty"`
+	Height      *float64 `json:"height,omitempty"`
+	Width       *float64 `json:"width,omitempty"`
+	Weight      *float64 `json:"weight,omitempty"`
+	AxleLoad    *float64 `json:"axle_load,omitempty"`
+	Hazmat      *bool    `json:"hazmat,omitempty"`
 }
 
 type DirectionsOptions struct {
-	Units string `json:"units,omitempty"`
+	Units    string `json:"units,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// Narrative languages Valhalla ships, other languages get the English narrative
+var narrativeLanguages = map[string]bool{
+	"bg": true, "ca": true, "cs": true, "da": true, "de": true, "el": true, "en": true,
+	"es": true, "et": true, "fi": true, "fr": true, "hi": true, "hu": true, "it": true,
+	"ja": true, "nb": true, "nl": true, "pl": true, "pt": true, "ro": true, "ru": true,
+	"sk": true, "sl": true, "sv": true, "tr": true, "uk": true,
+}
+
+// SupportsLanguage reports whether Valhalla can write the narrative in a language such as "de" or "pt-BR"
+func SupportsLanguage(lang string) bool {
+	if len(lang) >= 2 {
+		return narrativeLanguages[lang[:2]]
+	}
+	return false
+}
+
+// newCostingOptions sets the model options on the costing used by the request
+func newCostingOptions(costing string, modelOptions *CostingModelOptions) *CostingOptions {
+	switch costing {
+	case "truck":
+		return &CostingOptions{Truck: modelOptions}
+	case "motorcycle":
+		return &CostingOptions{Motorcycle: modelOptions}
+	case "bicycle":
+		return &CostingOptions{Bicycle: modelOptions}
+	case "pedestrian":
+		return &CostingOptions{Pedestrian: modelOptions}
+	default:
+		return &CostingOptions{Auto: modelOptions}
+	}
 }
 
 // Create a new ValhallaService instance
@@ -113,17 +176,59 @@ func (s *ValhallaService) GetRoute(locations []Location, options map[string]stri
 		if key == "costing" {
 			requestData.Costing = value
 		}
-		if key == "alternates" && value != "" {
+		if key == "alternates" && value != "" && value != "false" {
 			requestData.Alternates = 3
 		}
+		if (key == "depart_at" || key == "arrive_by") && value != "" {
+			at, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", key, err)
+			}
+			dateType := 1
+			if key == "arrive_by" {
+				dateType = 2
+			}
+			requestData.DateTime = &DateTime{Type: dateType, Value: at.Format("2006-01-02T15:04")}
+		}
+		if key == "lang" && SupportsLanguage(value) {
+			requestData.DirectionsOptions.Language = value
+		}
+		if key == "exclude_polygons" && value != "" {
+			if err := json.Unmarshal([]byte(value), &requestData.ExcludePolygons); err != nil {
+				return nil, fmt.Errorf("invalid exclude_polygons: %v", err)
+			}
+		}
 	}
 
-	// Set optional costing parameters
-	//if costing == "auto" {
-	//	requestData.CostingOptions.Auto.UseHighways = options["use_highways"]
-	//	requestData.CostingOptions.Auto.UseTolls = options["use_tolls"]
-	//	requestData.CostingOptions.Auto.UseFerry = options["use_ferry"]
-	//}
+	// Set optional costing parameters, 0 tells Valhalla to avoid the road type as much as possible
+	avoid := 0.0
+	modelOptions := CostingModelOptions{}
+	if options["avoid_highways"] == "true" {
+		modelOptions.UseHighways = &avoid
+	}
+	if options["avoid_tolls"] == "true" {
+		modelOptions.UseTolls = &avoid
+	}
+	if options["avoid_ferries"] == "true" {
+		modelOptions.UseFerry = &avoid
+	}
+	for key, target := range map[string]**float64{
+		"truck_height":    &modelOptions.Height,
+		"truck_width":     &modelOptions.Width,
+		"truck_weight":    &modelOptions.Weight,
+		"truck_axle_load": &modelOptions.AxleLoad,
+	} {
+		if value, err := strconv.ParseFloat(options[key], 64); err == nil && value > 0 {
+			*target = &value
+		}
+	}
+	if options["truck_hazmat"] == "true" {
+		hazmat := true
+		modelOptions.Hazmat = &hazmat
+	}
+	if modelOptions != (CostingModelOptions{}) {
+		requestData.CostingOptions = newCostingOptions(requestData.Costing, &modelOptions)
+	}
 
 	log.Printf("request is %v", requestData)
 	// Convert request to JSON
@@ -148,6 +253,9 @@ func (s *ValhallaService) GetRoute(locations []Location, options map[string]stri
 
 	// Read and parse response
 	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, &ServerError{StatusCode: resp.StatusCode, Message: errorMessage(bodyBytes)}
+	}
 
 	var routeResponse RouteResponse
 	if err := json.Unmarshal(bodyBytes, &routeResponse); err != nil {
@@ -159,3 +267,100 @@ func (s *ValhallaService) GetRoute(locations []Location, options map[string]stri
 
 	return &routeResponse, nil
 }
+
+// Function to request an optimized (reordered) route from Valhalla API, the first and last locations stay fixed
+func (s *ValhallaService) GetOptimizedRoute(locations []Location, options map[string]string) (*RouteResponse, error) {
+	url := fmt.Sprintf("%s/optimized_route", s.BaseURL)
+
+	requestData := RouteRequest{
+		Locations: locations,
+		DirectionsOptions: DirectionsOptions{
+			Units: "kilometers",
+		},
+	}
+
+	for key, value := range options {
+		if key == "costing" {
+			requestData.Costing = value
+		}
+	}
+
+	var routeResponse RouteResponse
+	if err := s.post(url, requestData, &routeResponse); err != nil {
+		log.Printf("Error decoding optimized route response: %v", err)
+		return nil, err
+	}
+
+	return &routeResponse, nil
+}
+
+// ServerError is a Valhalla answer with a 5xx status, the service failed rather than the request
+type ServerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("valhalla server error %d: %s", e.StatusCode, e.Message)
+}
+
+// post sends a JSON payload to Valhalla and decodes the JSON answer into out
+func (s *ValhallaService) post(url string, payload interface{}, out interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	// Valhalla reports failures as {"error_code": ..., "error": "..."}
+	if resp.StatusCode != http.StatusOK {
+		var errorResponse struct {
+			ErrorCode int    `json:"error_code"`
+			Error     string `json:"error"`
+		}
+		_ = json.Unmarshal(bodyBytes, &errorResponse)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return &ServerError{StatusCode: resp.StatusCode, Message: errorResponse.Error}
+		}
+		return fmt.Errorf("valhalla error %d: %s", errorResponse.ErrorCode, errorResponse.Error)
+	}
+
+	return json.Unmarshal(bodyBytes, out)
+}
+
+// errorMessage returns the error text of a Valhalla error body
+func errorMessage(body []byte) string {
+	var errorResponse struct {
+		Error string `json:"error"`
+	}
+	_ = json.Unmarshal(body, &errorResponse)
+	return errorResponse.Error
+}
+
+// Status probes the Valhalla /status endpoint
+func (s *ValhallaService) Status() error {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("%s/status", s.BaseURL))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("valhalla status returned %d", resp.StatusCode)
+	}
+	return nil
+}
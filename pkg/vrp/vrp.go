@@ -0,0 +1,431 @@
+package vrp
+
+import (
+	"math"
+	"time"
+)
+
+// Reasons reported for jobs that could not be assigned to any vehicle
+const (
+	ReasonCapacity    = "capacity_exceeded"
+	ReasonUnreachable = "unreachable"
+	ReasonTimeWindow  = "time_window"
+	ReasonNoVehicle   = "no_vehicle_available"
+)
+
+// Problem describes a fleet planning request. Locations are indexes into the
+// Durations (seconds) and Distances matrices, times are seconds from the plan start.
+type Problem struct {
+	Vehicles  []Vehicle
+	Jobs      []Job
+	Durations [][]float64
+	Distances [][]float64
+}
+
+// Vehicle with a capacity and a shift, EndLocation < 0 means the route ends at its last job
+type Vehicle struct {
+	ID            string
+	StartLocation int
+	EndLocation   int
+	Capacity      int
+	ShiftStart    float64
+	ShiftEnd      float64
+}
+
+// Job with a demand, a service time and a delivery window
+type Job struct {
+	ID          string
+	Location    int
+	Demand      int
+	Service     float64
+	WindowStart float64
+	WindowEnd   float64
+}
+
+// Options tune the search
+type Options struct {
+	TimeLimit time.Duration
+}
+
+// Solution is the planned routes plus the jobs that could not be served
+type Solution struct {
+	Routes        []Route
+	Unassigned    []Unassigned
+	TotalDuration float64
+	TotalDistance float64
+}
+
+// Route is the ordered list of stops of one vehicle
+type Route struct {
+	VehicleID string
+	Stops     []Stop
+	Load      int
+	Start     float64
+	End       float64
+	Duration  float64
+	Distance  float64
+}
+
+// Stop is a scheduled job visit
+type Stop struct {
+	JobID     string
+	Location  int
+	Arrival   float64
+	Wait      float64
+	Departure float64
+	Load      int
+}
+
+// Unassigned is a job left out of the plan and the reason why
+type Unassigned struct {
+	JobID  string
+	Reason string
+}
+
+// schedule is the evaluation of one vehicle route
+type schedule struct {
+	cost     float64
+	distance float64
+	end      float64
+	load     int
+	stops    []Stop
+}
+
+type solver struct {
+	problem  Problem
+	routes   [][]int
+	deadline time.Time
+}
+
+// Solve builds routes with a regret insertion heuristic and improves them with local search
+func Solve(problem Problem, options Options) Solution {
+	if options.TimeLimit <= 0 {
+		options.TimeLimit = 10 * time.Second
+	}
+	s := &solver{
+		problem:  problem,
+		routes:   make([][]int, len(problem.Vehicles)),
+		deadline: time.Now().Add(options.TimeLimit),
+	}
+
+	pending, unassigned := s.precheck()
+	pending = s.construct(pending)
+	s.improve()
+
+	for _, j := range pending {
+		unassigned = append(unassigned, Unassigned{JobID: problem.Jobs[j].ID, Reason: ReasonNoVehicle})
+	}
+
+	return s.solution(unassigned)
+}
+
+// precheck removes the jobs no vehicle could ever serve on its own
+func (s *solver) precheck() ([]int, []Unassigned) {
+	var pending []int
+	var unassigned []Unassigned
+
+	for j, job := range s.problem.Jobs {
+		capacityOK, reachable, windowOK := false, false, false
+		for v, vehicle := range s.problem.Vehicles {
+			if job.Demand > vehicle.Capacity {
+				continue
+			}
+			capacityOK = true
+			if !s.reachable(vehicle, job) {
+				continue
+			}
+			reachable = true
+			if _, ok := s.evaluate(v, []int{j}); ok {
+				windowOK = true
+				break
+			}
+		}
+
+		switch {
+		case !capacityOK:
+			unassigned = append(unassigned, Unassigned{JobID: job.ID, Reason: ReasonCapacity})
+		case !reachable:
+			unassigned = append(unassigned, Unassigned{JobID: job.ID, Reason: ReasonUnreachable})
+		case !windowOK:
+			unassigned = append(unassigned, Unassigned{JobID: job.ID, Reason: ReasonTimeWindow})
+		default:
+			pending = append(pending, j)
+		}
+	}
+
+	return pending, unassigned
+}
+
+func (s *solver) reachable(vehicle Vehicle, job Job) bool {
+	if math.IsInf(s.problem.Durations[vehicle.StartLocation][job.Location], 1) {
+		return false
+	}
+	if vehicle.EndLocation >= 0 && math.IsInf(s.problem.Durations[job.Location][vehicle.EndLocation], 1) {
+		return false
+	}
+	return true
+}
+
+// construct inserts jobs by regret: the job that loses most when it misses its best slot goes first
+func (s *solver) construct(pending []int) []int {
+	for len(pending) > 0 {
+		bestJob, bestVehicle, bestPosition := -1, -1, -1
+		bestRegret := math.Inf(-1)
+
+		for index, j := range pending {
+			first, second := math.Inf(1), math.Inf(1)
+			vehicle, position := -1, -1
+
+			for v := range s.routes {
+				current, _ := s.evaluate(v, s.routes[v])
+				// Keep only the best position per vehicle so regret compares vehicles
+				vehicleBest, vehiclePosition := math.Inf(1), -1
+				for p := 0; p <= len(s.routes[v]); p++ {
+					candidate, ok := s.evaluate(v, insertAt(s.routes[v], p, j))
+					if !ok {
+						continue
+					}
+					if delta := candidate.cost - current.cost; delta < vehicleBest {
+						vehicleBest, vehiclePosition = delta, p
+					}
+				}
+				if vehiclePosition < 0 {
+					continue
+				}
+				if vehicleBest < first {
+					second = first
+					first, vehicle, position = vehicleBest, v, vehiclePosition
+				} else if vehicleBest < second {
+					second = vehicleBest
+				}
+			}
+
+			if vehicle < 0 {
+				continue
+			}
+			regret := second - first
+			if math.IsInf(second, 1) {
+				// Jobs with a single option must be placed before it disappears
+				regret = math.MaxFloat64
+			}
+			if regret > bestRegret {
+				bestRegret, bestJob, bestVehicle, bestPosition = regret, index, vehicle, position
+			}
+		}
+
+		if bestJob < 0 {
+			break
+		}
+		j := pending[bestJob]
+		s.routes[bestVehicle] = insertAt(s.routes[bestVehicle], bestPosition, j)
+		pending = append(pending[:bestJob], pending[bestJob+1:]...)
+	}
+
+	return pending
+}
+
+// improve runs relocate, swap and 2-opt moves until no move helps or the time runs out
+func (s *solver) improve() {
+	for time.Now().Before(s.deadline) {
+		if s.relocate() || s.swap() || s.twoOpt() {
+			continue
+		}
+		return
+	}
+}
+
+// relocate moves one job to its best position in any route
+func (s *solver) relocate() bool {
+	for from := range s.routes {
+		for i := 0; i < len(s.routes[from]); i++ {
+			j := s.routes[from][i]
+			without := removeAt(s.routes[from], i)
+			fromBefore, _ := s.evaluate(from, s.routes[from])
+			fromAfter, ok := s.evaluate(from, without)
+			if !ok {
+				// Without triangle inequality on road times a removal can break a window
+				continue
+			}
+
+			for to := range s.routes {
+				base := s.routes[to]
+				if to == from {
+					base = without
+				}
+				toBefore, _ := s.evaluate(to, s.routes[to])
+				for p := 0; p <= len(base); p++ {
+					if to == from && p == i {
+						continue
+					}
+					candidate, ok := s.evaluate(to, insertAt(base, p, j))
+					if !ok {
+						continue
+					}
+
+					var gain float64
+					if to == from {
+						gain = fromBefore.cost - candidate.cost
+					} else {
+						gain = fromBefore.cost + toBefore.cost - fromAfter.cost - candidate.cost
+					}
+					if gain > 1e-6 {
+						s.routes[from] = without
+						s.routes[to] = insertAt(base, p, j)
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// swap exchanges two jobs between different routes
+func (s *solver) swap() bool {
+	for a := range s.routes {
+		for b := a + 1; b < len(s.routes); b++ {
+			before := s.cost(a, s.routes[a]) + s.cost(b, s.routes[b])
+			for i := range s.routes[a] {
+				for k := range s.routes[b] {
+					routeA := append([]int{}, s.routes[a]...)
+					routeB := append([]int{}, s.routes[b]...)
+					routeA[i], routeB[k] = routeB[k], routeA[i]
+
+					scheduleA, okA := s.evaluate(a, routeA)
+					scheduleB, okB := s.evaluate(b, routeB)
+					if okA && okB && before-scheduleA.cost-scheduleB.cost > 1e-6 {
+						s.routes[a], s.routes[b] = routeA, routeB
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// twoOpt reverses a segment inside a route
+func (s *solver) twoOpt() bool {
+	for v, route := range s.routes {
+		before := s.cost(v, route)
+		for i := 0; i < len(route)-1; i++ {
+			for k := i + 1; k < len(route); k++ {
+				candidate := append([]int{}, route...)
+				for l, r := i, k; l < r; l, r = l+1, r-1 {
+					candidate[l], candidate[r] = candidate[r], candidate[l]
+				}
+				if after, ok := s.evaluate(v, candidate); ok && before-after.cost > 1e-6 {
+					s.routes[v] = candidate
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (s *solver) cost(v int, route []int) float64 {
+	sched, _ := s.evaluate(v, route)
+	return sched.cost
+}
+
+// evaluate schedules the jobs on vehicle v and reports whether capacity, windows and shift hold.
+// The cost is the time spent driving and waiting.
+func (s *solver) evaluate(v int, route []int) (schedule, bool) {
+	vehicle := s.problem.Vehicles[v]
+	var result schedule
+
+	for _, j := range route {
+		result.load += s.problem.Jobs[j].Demand
+	}
+	if result.load > vehicle.Capacity {
+		return result, false
+	}
+	if len(route) == 0 {
+		return result, true
+	}
+
+	t := vehicle.ShiftStart
+	location := vehicle.StartLocation
+	load := result.load
+	for _, j := range route {
+		job := s.problem.Jobs[j]
+		travel := s.problem.Durations[location][job.Location]
+		if math.IsInf(travel, 1) {
+			return result, false
+		}
+		arrival := t + travel
+		wait := math.Max(0, job.WindowStart-arrival)
+		if arrival+wait > job.WindowEnd {
+			return result, false
+		}
+		load -= job.Demand
+
+		result.cost += travel + wait
+		result.distance += s.problem.Distances[location][job.Location]
+		t = arrival + wait + job.Service
+		result.stops = append(result.stops, Stop{
+			JobID:     job.ID,
+			Location:  job.Location,
+			Arrival:   arrival,
+			Wait:      wait,
+			Departure: t,
+			Load:      load,
+		})
+		location = job.Location
+	}
+
+	if vehicle.EndLocation >= 0 {
+		travel := s.problem.Durations[location][vehicle.EndLocation]
+		if math.IsInf(travel, 1) {
+			return result, false
+		}
+		result.cost += travel
+		result.distance += s.problem.Distances[location][vehicle.EndLocation]
+		t += travel
+	}
+	if t > vehicle.ShiftEnd {
+		return result, false
+	}
+	result.end = t
+
+	return result, true
+}
+
+func (s *solver) solution(unassigned []Unassigned) Solution {
+	solution := Solution{Unassigned: unassigned}
+
+	for v, route := range s.routes {
+		if len(route) == 0 {
+			continue
+		}
+		sched, _ := s.evaluate(v, route)
+		vehicle := s.problem.Vehicles[v]
+		solution.Routes = append(solution.Routes, Route{
+			VehicleID: vehicle.ID,
+			Stops:     sched.stops,
+			Load:      sched.load,
+			Start:     vehicle.ShiftStart,
+			End:       sched.end,
+			Duration:  sched.end - vehicle.ShiftStart,
+			Distance:  sched.distance,
+		})
+		solution.TotalDuration += sched.end - vehicle.ShiftStart
+		solution.TotalDistance += sched.distance
+	}
+
+	return solution
+}
+
+func insertAt(route []int, position, job int) []int {
+	result := make([]int, 0, len(route)+1)
+	result = append(result, route[:position]...)
+	result = append(result, job)
+	return append(result, route[position:]...)
+}
+
+func removeAt(route []int, position int) []int {
+	result := make([]int, 0, len(route)-1)
+	result = append(result, route[:position]...)
+	return append(result, route[position+1:]...)
+}
@@ -0,0 +1,195 @@
+package vrp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// lineProblem places the locations on a straight road, a kilometer takes a minute
+func lineProblem(positions []float64, vehicles []Vehicle, jobs []Job) Problem {
+	problem := Problem{Vehicles: vehicles, Jobs: jobs}
+	for _, from := range positions {
+		var durations, distances []float64
+		for _, to := range positions {
+			durations = append(durations, math.Abs(to-from)*60)
+			distances = append(distances, math.Abs(to-from))
+		}
+		problem.Durations = append(problem.Durations, durations)
+		problem.Distances = append(problem.Distances, distances)
+	}
+	return problem
+}
+
+func vehicle(id string, start, capacity int) Vehicle {
+	return Vehicle{ID: id, StartLocation: start, EndLocation: -1, Capacity: capacity, ShiftEnd: math.Inf(1)}
+}
+
+func job(id string, location, demand int) Job {
+	return Job{ID: id, Location: location, Demand: demand, WindowEnd: math.Inf(1)}
+}
+
+func stopIDs(route Route) []string {
+	var ids []string
+	for _, stop := range route.Stops {
+		ids = append(ids, stop.JobID)
+	}
+	return ids
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		problem    Problem
+		routes     map[string][]string
+		unassigned []Unassigned
+	}{
+		{
+			name: "stops along a road are visited in order",
+			problem: lineProblem([]float64{0, 3, 1, 2}, []Vehicle{vehicle("van", 0, 10)},
+				[]Job{job("c", 1, 1), job("a", 2, 1), job("b", 3, 1)}),
+			routes: map[string][]string{"van": {"a", "b", "c"}},
+		},
+		{
+			name: "a job above every capacity is unassigned",
+			problem: lineProblem([]float64{0, 1, 2}, []Vehicle{vehicle("van", 0, 5)},
+				[]Job{job("pallet", 1, 7), job("parcel", 2, 1)}),
+			routes:     map[string][]string{"van": {"parcel"}},
+			unassigned: []Unassigned{{JobID: "pallet", Reason: ReasonCapacity}},
+		},
+		{
+			name: "jobs overflowing the fleet capacity together are left out",
+			problem: lineProblem([]float64{0, 1, 2}, []Vehicle{vehicle("van", 0, 5)},
+				[]Job{job("a", 1, 3), job("b", 2, 3)}),
+			routes:     map[string][]string{"van": {"a"}},
+			unassigned: []Unassigned{{JobID: "b", Reason: ReasonNoVehicle}},
+		},
+		{
+			name: "an unreachable job is unassigned",
+			problem: func() Problem {
+				problem := lineProblem([]float64{0, 1, 2}, []Vehicle{vehicle("van", 0, 5)},
+					[]Job{job("island", 1, 1), job("shop", 2, 1)})
+				problem.Durations[0][1] = math.Inf(1)
+				return problem
+			}(),
+			routes:     map[string][]string{"van": {"shop"}},
+			unassigned: []Unassigned{{JobID: "island", Reason: ReasonUnreachable}},
+		},
+		{
+			name: "a window closing before the vehicle can arrive is unassigned",
+			problem: lineProblem([]float64{0, 10}, []Vehicle{vehicle("van", 0, 5)},
+				[]Job{{ID: "late", Location: 1, Demand: 1, WindowEnd: 300}}),
+			routes:     map[string][]string{},
+			unassigned: []Unassigned{{JobID: "late", Reason: ReasonTimeWindow}},
+		},
+		{
+			name: "a job past the shift end is unassigned",
+			problem: lineProblem([]float64{0, 10}, []Vehicle{{ID: "van", EndLocation: -1, Capacity: 5, ShiftEnd: 500}},
+				[]Job{job("far", 1, 1)}),
+			routes:     map[string][]string{},
+			unassigned: []Unassigned{{JobID: "far", Reason: ReasonTimeWindow}},
+		},
+		{
+			name: "windows reorder the stops",
+			// Without windows the van would stop at 1 km then 5 km, the 5 km window closes at 400 s
+			problem: lineProblem([]float64{0, 1, 5}, []Vehicle{vehicle("van", 0, 5)},
+				[]Job{
+					{ID: "near", Location: 1, Demand: 1, WindowStart: 1200, WindowEnd: math.Inf(1)},
+					{ID: "far", Location: 2, Demand: 1, WindowEnd: 400},
+				}),
+			routes: map[string][]string{"van": {"far", "near"}},
+		},
+		{
+			name: "each vehicle serves its own side",
+			problem: lineProblem([]float64{0, 100, 1, 2, 99, 98},
+				[]Vehicle{vehicle("west", 0, 5), vehicle("east", 1, 5)},
+				[]Job{job("w1", 2, 1), job("e1", 4, 1), job("w2", 3, 1), job("e2", 5, 1)}),
+			routes: map[string][]string{"west": {"w1", "w2"}, "east": {"e1", "e2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solution := Solve(tt.problem, Options{TimeLimit: time.Second})
+
+			routes := map[string][]string{}
+			for _, route := range solution.Routes {
+				routes[route.VehicleID] = stopIDs(route)
+			}
+			if !reflect.DeepEqual(routes, tt.routes) {
+				t.Errorf("routes = %v, want %v", routes, tt.routes)
+			}
+			if !reflect.DeepEqual(solution.Unassigned, tt.unassigned) {
+				t.Errorf("unassigned = %v, want %v", solution.Unassigned, tt.unassigned)
+			}
+		})
+	}
+}
+
+func TestSolveRespectsWindowsAndCapacity(t *testing.T) {
+	problem := lineProblem([]float64{0, 1, 5}, []Vehicle{vehicle("van", 0, 5)},
+		[]Job{
+			{ID: "near", Location: 1, Demand: 2, Service: 60, WindowStart: 1200, WindowEnd: 1800},
+			{ID: "far", Location: 2, Demand: 3, Service: 60, WindowEnd: 400},
+		})
+	solution := Solve(problem, Options{TimeLimit: time.Second})
+	if len(solution.Routes) != 1 {
+		t.Fatalf("routes = %+v, want one", solution.Routes)
+	}
+	route := solution.Routes[0]
+
+	// far: 5 km at 300 s, served until 360 s. near: 4 km back at 600 s, waits for its window at 1200 s
+	want := []Stop{
+		{JobID: "far", Location: 2, Arrival: 300, Wait: 0, Departure: 360, Load: 2},
+		{JobID: "near", Location: 1, Arrival: 600, Wait: 600, Departure: 1260, Load: 0},
+	}
+	if !reflect.DeepEqual(route.Stops, want) {
+		t.Errorf("stops = %+v, want %+v", route.Stops, want)
+	}
+	if route.Load != 5 || route.Distance != 9 || route.End != 1260 {
+		t.Errorf("load %d, distance %v, end %v, want 5, 9 and 1260", route.Load, route.Distance, route.End)
+	}
+	for _, stop := range route.Stops {
+		job := problem.Jobs[stop.Location-1]
+		if stop.Arrival+stop.Wait < job.WindowStart || stop.Arrival+stop.Wait > job.WindowEnd {
+			t.Errorf("stop %s starts at %v, outside [%v, %v]", stop.JobID, stop.Arrival+stop.Wait, job.WindowStart, job.WindowEnd)
+		}
+	}
+}
+
+func TestSolveIsDeterministic(t *testing.T) {
+	positions := []float64{0, 40, 3, 17, 29, 8, 35, 12, 22, 5, 31, 26, 14, 38}
+	vehicles := []Vehicle{vehicle("a", 0, 6), vehicle("b", 1, 6), vehicle("c", 0, 4)}
+	var jobs []Job
+	for location := 2; location < len(positions); location++ {
+		jobs = append(jobs, Job{
+			ID:          fmt.Sprintf("job-%d", location),
+			Location:    location,
+			Demand:      1 + location%3,
+			Service:     120,
+			WindowStart: float64(location%4) * 600,
+			WindowEnd:   math.Inf(1),
+		})
+	}
+	problem := lineProblem(positions, vehicles, jobs)
+
+	first := Solve(problem, Options{TimeLimit: time.Second})
+	for i := 0; i < 5; i++ {
+		if again := Solve(problem, Options{TimeLimit: time.Second}); !reflect.DeepEqual(first, again) {
+			t.Fatalf("run %d differs:\n%+v\n%+v", i+2, again, first)
+		}
+	}
+
+	assigned := len(first.Unassigned)
+	for _, route := range first.Routes {
+		assigned += len(route.Stops)
+		if route.Load > 6 {
+			t.Errorf("vehicle %s carries %d", route.VehicleID, route.Load)
+		}
+	}
+	if assigned != len(jobs) {
+		t.Errorf("%d jobs planned or unassigned, want %d", assigned, len(jobs))
+	}
+}